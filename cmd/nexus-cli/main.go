@@ -1,14 +1,75 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"html/template"
+	"io/ioutil"
 	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
 
-	"github.com/Seekr-Technologies/nexus-cli/internal/pkg/registry"
+	"github.com/Seekr-Technologies/nexus-cli/internal/package/registry"
 	"github.com/urfave/cli"
+	"github.com/vbauerster/mpb/v8"
+	"github.com/vbauerster/mpb/v8/decor"
 )
 
+// cancelOnInterrupt returns a context that's cancelled on Ctrl-C, so an
+// in-progress pooled operation and its progress bar abort cleanly instead of
+// leaving goroutines running
+func cancelOnInterrupt() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	go func() {
+		<-sig
+		cancel()
+	}()
+	return ctx, cancel
+}
+
+// stringComparator orders two strings; Compare wraps one in a sorter with a
+// Sort method, so a one-off ordering reads as a fluent `Compare(less).Sort(tags)`
+// instead of a bare sort.Slice callback.
+type stringComparator func(a, b string) bool
+
+// Compare builds a sorter from a "less" function over strings
+func Compare(less stringComparator) stringComparator {
+	return less
+}
+
+// Sort orders tags in place using the wrapped comparator
+func (less stringComparator) Sort(tags []string) {
+	sort.SliceStable(tags, func(i, j int) bool {
+		return less(tags[i], tags[j])
+	})
+}
+
+// extractNumberFromString pulls the first run of digits out of s, e.g.
+// "build-42" -> 42, so tags can be ordered by their embedded build/version
+// number instead of lexically, where "v10" would otherwise sort before "v9"
+func extractNumberFromString(s string) int {
+	var digits strings.Builder
+	for _, r := range s {
+		if unicode.IsDigit(r) {
+			digits.WriteRune(r)
+		} else if digits.Len() > 0 {
+			break
+		}
+	}
+	if digits.Len() == 0 {
+		return 0
+	}
+	n, _ := strconv.Atoi(digits.String())
+	return n
+}
+
 const (
 	credentialsTemplates = `# Nexus Credentials
 nexus_host = "{{ .Host }}"
@@ -22,6 +83,13 @@ func main() {
 	app.Name = "Nexus CLI"
 	app.Usage = "Manage Docker Private Registry on Nexus"
 	app.Version = "v1.0.3"
+	app.Flags = []cli.Flag{
+		cli.StringFlag{
+			Name:  "profile",
+			Value: "default",
+			Usage: "Named Nexus credentials profile to use",
+		},
+	}
 	app.Authors = []cli.Author{
 		{
 			Name:  "Mohamed Labouardy",
@@ -50,6 +118,26 @@ func main() {
 				return setNexusCredentials(c)
 			},
 		},
+		{
+			Name:  "login",
+			Usage: "Log in to a Nexus Docker registry",
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "host"},
+				cli.StringFlag{Name: "repository"},
+				cli.StringFlag{Name: "username, u"},
+				cli.StringFlag{Name: "password, p"},
+			},
+			Action: func(c *cli.Context) error {
+				return login(c)
+			},
+		},
+		{
+			Name:  "logout",
+			Usage: "Remove saved Nexus credentials for a profile",
+			Action: func(c *cli.Context) error {
+				return logout(c)
+			},
+		},
 		{
 			Name:  "image",
 			Usage: "Manage Docker Images",
@@ -69,6 +157,14 @@ func main() {
 							Name:  "name, n",
 							Usage: "List tags by image name",
 						},
+						cli.IntFlag{
+							Name:  "concurrency, c",
+							Usage: "Number of concurrent Nexus API calls (default: number of CPUs)",
+						},
+						cli.IntFlag{
+							Name:  "rate, r",
+							Usage: "Max pooled tasks (one per image or tag) started per second (default: unlimited)",
+						},
 					},
 					Action: func(c *cli.Context) error {
 						return listTagsByImage(c)
@@ -84,6 +180,10 @@ func main() {
 						cli.StringFlag{
 							Name: "tag, t",
 						},
+						cli.StringFlag{
+							Name:  "platform",
+							Usage: "Resolve a multi-arch tag to a single platform, e.g. linux/amd64",
+						},
 					},
 					Action: func(c *cli.Context) error {
 						return showImageSha(c)
@@ -99,6 +199,10 @@ func main() {
 						cli.StringFlag{
 							Name: "tag, t",
 						},
+						cli.StringFlag{
+							Name:  "platform",
+							Usage: "Resolve a multi-arch tag to a single platform, e.g. linux/amd64",
+						},
 					},
 					Action: func(c *cli.Context) error {
 						return showImageInfo(c)
@@ -117,11 +221,61 @@ func main() {
 						cli.StringFlag{
 							Name: "keep, k",
 						},
+						cli.BoolFlag{
+							Name:  "force, f",
+							Usage: "Delete a signed tag anyway",
+						},
+						cli.IntFlag{
+							Name:  "concurrency, c",
+							Usage: "Number of concurrent Nexus API calls (default: number of CPUs)",
+						},
+						cli.IntFlag{
+							Name:  "rate, r",
+							Usage: "Max pooled tasks (one per image or tag) started per second (default: unlimited)",
+						},
 					},
 					Action: func(c *cli.Context) error {
 						return deleteImage(c)
 					},
 				},
+				{
+					Name:  "sign",
+					Usage: "Sign an image tag",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name: "name, n",
+						},
+						cli.StringFlag{
+							Name: "tag, t",
+						},
+						cli.StringFlag{
+							Name:  "key",
+							Usage: "Path to a PEM encoded ECDSA or ed25519 private key",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						return signImage(c)
+					},
+				},
+				{
+					Name:  "verify",
+					Usage: "Verify an image tag's signature",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name: "name, n",
+						},
+						cli.StringFlag{
+							Name: "tag, t",
+						},
+						cli.StringFlag{
+							Name:  "key",
+							Usage: "Path to a PEM encoded ECDSA or ed25519 public key",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						return verifyImage(c)
+					},
+				},
 				{
 					Name:  "size",
 					Usage: "Show total size of image including all tags",
@@ -129,6 +283,18 @@ func main() {
 						cli.StringFlag{
 							Name: "name, n",
 						},
+						cli.IntFlag{
+							Name:  "concurrency, c",
+							Usage: "Number of concurrent Nexus API calls (default: number of CPUs)",
+						},
+						cli.IntFlag{
+							Name:  "rate, r",
+							Usage: "Max pooled tasks (one per image or tag) started per second (default: unlimited)",
+						},
+						cli.StringFlag{
+							Name:  "platform",
+							Usage: "Only size a single platform of a multi-arch tag, e.g. linux/amd64",
+						},
 					},
 					Action: func(c *cli.Context) error {
 						return showTotalImageSize(c)
@@ -136,6 +302,42 @@ func main() {
 				},
 			},
 		},
+		{
+			Name:  "retention",
+			Usage: "Apply declarative tag retention policies",
+			Subcommands: []cli.Command{
+				{
+					Name:  "apply",
+					Usage: "Delete tags that no rule in a retention.yaml protects",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "file, f",
+							Usage: "Path to a retention policy YAML file",
+						},
+						cli.BoolFlag{
+							Name:  "dry-run",
+							Usage: "Only print what would be deleted",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						return applyRetentionPolicy(c)
+					},
+				},
+			},
+		},
+		{
+			Name:  "gc",
+			Usage: "Reclaim space held by blobs no longer referenced by any tag",
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "dry-run",
+					Usage: "Only print what would be deleted",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				return garbageCollect(c)
+			},
+		},
 	}
 	app.CommandNotFound = func(c *cli.Context, command string) {
 		fmt.Fprintf(c.App.Writer, "Wrong command %q !", command)
@@ -183,8 +385,43 @@ func setNexusCredentials(c *cli.Context) error {
 	return nil
 }
 
+func login(c *cli.Context) error {
+	var hostname, repository, username, password = c.String("host"), c.String("repository"), c.String("username"), c.String("password")
+	if hostname == "" {
+		fmt.Print("Enter Nexus Host: ")
+		fmt.Scan(&hostname)
+	}
+	if repository == "" {
+		fmt.Print("Enter Nexus Repository Name: ")
+		fmt.Scan(&repository)
+	}
+	if username == "" {
+		fmt.Print("Enter Nexus Username: ")
+		fmt.Scan(&username)
+	}
+	if password == "" {
+		fmt.Print("Enter Nexus Password: ")
+		fmt.Scan(&password)
+	}
+
+	r := registry.Registry{Host: hostname, Username: username, Password: password, Repository: repository}
+	if err := registry.Login(c.GlobalString("profile"), r); err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	fmt.Println("Login Succeeded")
+	return nil
+}
+
+func logout(c *cli.Context) error {
+	if err := registry.Logout(c.GlobalString("profile")); err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	fmt.Println("Removed login credentials")
+	return nil
+}
+
 func listImages(c *cli.Context) error {
-	r, err := registry.NewRegistry()
+	r, err := registry.NewRegistryWithProfile(c.GlobalString("profile"))
 	if err != nil {
 		return cli.NewExitError(err.Error(), 1)
 	}
@@ -199,36 +436,101 @@ func listImages(c *cli.Context) error {
 	return nil
 }
 
+type imageTagsResult struct {
+	image string
+	tags  []string
+	err   error
+}
+
 func listTagsByImage(c *cli.Context) error {
 	var imgName = c.String("name")
-	r, err := registry.NewRegistry()
+	r, err := registry.NewRegistryWithProfile(c.GlobalString("profile"))
 	if err != nil {
 		return cli.NewExitError(err.Error(), 1)
 	}
-	if imgName == "" {
-		cli.ShowSubcommandHelp(c)
-	}
-	tags, err := r.ListTagsByImage(imgName)
 
-	compareStringNumber := func(str1, str2 string) bool {
-		return extractNumberFromString(str1) < extractNumberFromString(str2)
+	if imgName != "" {
+		tags, err := r.ListTagsByImage(imgName)
+
+		compareStringNumber := func(str1, str2 string) bool {
+			return extractNumberFromString(str1) < extractNumberFromString(str2)
+		}
+		Compare(compareStringNumber).Sort(tags)
+
+		if err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		for _, tag := range tags {
+			fmt.Println(tag)
+		}
+		fmt.Printf("There are %d images for %s\n", len(tags), imgName)
+		return nil
 	}
-	Compare(compareStringNumber).Sort(tags)
 
+	// No --name given: fan ListTagsByImage out across every image in the
+	// repository through the pool instead of listing them one at a time.
+	images, err := r.ListImages()
 	if err != nil {
 		return cli.NewExitError(err.Error(), 1)
 	}
-	for _, tag := range tags {
-		fmt.Println(tag)
+
+	ctx, cancel := cancelOnInterrupt()
+	defer cancel()
+
+	progress := mpb.NewWithContext(ctx)
+	bar := progress.New(int64(len(images)),
+		mpb.BarStyle().Rbound("|"),
+		mpb.PrependDecorators(decor.Name("tags")),
+		mpb.AppendDecorators(decor.CountersNoUnit("%d / %d")),
+	)
+	bar.EnableTriggerComplete()
+
+	pool := registry.NewPool(c.Int("concurrency"), c.Int("rate"))
+	results := make(chan imageTagsResult)
+	var wg sync.WaitGroup
+
+	// Submitting happens on its own goroutine so the range over results
+	// below can start draining immediately: if concurrency is lower than
+	// len(images), a worker blocked sending its result would otherwise
+	// deadlock against this goroutine still being stuck submitting more
+	// work.
+	go func() {
+		for _, image := range images {
+			image := image
+			pool.Submit(ctx, &wg, func() {
+				defer bar.Increment()
+				tags, err := registry.Retry(ctx, 0, func() ([]string, error) {
+					return r.ListTagsByImage(image)
+				})
+				select {
+				case results <- imageTagsResult{image: image, tags: tags, err: err}:
+				case <-ctx.Done():
+				}
+			})
+		}
+		wg.Wait()
+		close(results)
+	}()
+
+	total := 0
+	for result := range results {
+		if result.err != nil {
+			return cli.NewExitError(result.err.Error(), 1)
+		}
+		for _, tag := range result.tags {
+			fmt.Printf("%s:%s\n", result.image, tag)
+		}
+		total += len(result.tags)
 	}
-	fmt.Printf("There are %d images for %s\n", len(tags), imgName)
+	progress.Wait()
+	fmt.Printf("There are %d tags across %d images\n", total, len(images))
 	return nil
 }
 
 func showImageSha(c *cli.Context) error {
 	var imgName = c.String("name")
 	var tag = c.String("tag")
-	r, err := registry.NewRegistry()
+	r, err := registry.NewRegistryWithProfile(c.GlobalString("profile"))
 	if err != nil {
 		return cli.NewExitError(err.Error(), 1)
 	}
@@ -236,7 +538,7 @@ func showImageSha(c *cli.Context) error {
 		cli.ShowSubcommandHelp(c)
 	}
 
-	sha, err := r.GetImageSHA(imgName, tag)
+	sha, err := r.GetImageSHAForPlatform(imgName, tag, c.String("platform"))
 	if err != nil {
 		return cli.NewExitError(err.Error(), 1)
 	}
@@ -249,14 +551,14 @@ func showImageSha(c *cli.Context) error {
 func showImageInfo(c *cli.Context) error {
 	var imgName = c.String("name")
 	var tag = c.String("tag")
-	r, err := registry.NewRegistry()
+	r, err := registry.NewRegistryWithProfile(c.GlobalString("profile"))
 	if err != nil {
 		return cli.NewExitError(err.Error(), 1)
 	}
 	if imgName == "" || tag == "" {
 		cli.ShowSubcommandHelp(c)
 	}
-	manifest, err := r.ImageManifest(imgName, tag)
+	manifest, err := r.ImageManifestForPlatform(imgName, tag, c.String("platform"))
 	if err != nil {
 		return cli.NewExitError(err.Error(), 1)
 	}
@@ -277,7 +579,7 @@ func deleteImage(c *cli.Context) error {
 		fmt.Fprintf(c.App.Writer, "You should specify the image name\n")
 		cli.ShowSubcommandHelp(c)
 	} else {
-		r, err := registry.NewRegistry()
+		r, err := registry.NewRegistryWithProfile(c.GlobalString("profile"))
 		if err != nil {
 			return cli.NewExitError(err.Error(), 1)
 		}
@@ -295,15 +597,59 @@ func deleteImage(c *cli.Context) error {
 					return cli.NewExitError(err.Error(), 1)
 				}
 				if len(tags) >= keep {
-					for _, tag := range tags[:len(tags)-keep] {
-						fmt.Printf("%s:%s image will be deleted ...\n", imgName, tag)
-						r.DeleteImageByTag(imgName, tag)
+					toDelete := tags[:len(tags)-keep]
+
+					ctx, cancel := cancelOnInterrupt()
+					defer cancel()
+
+					progress := mpb.NewWithContext(ctx)
+					bar := progress.New(int64(len(toDelete)),
+						mpb.BarStyle().Rbound("|"),
+						mpb.PrependDecorators(decor.Name(imgName)),
+						mpb.AppendDecorators(decor.CountersNoUnit("%d / %d")),
+					)
+					bar.EnableTriggerComplete()
+
+					pool := registry.NewPool(c.Int("concurrency"), c.Int("rate"))
+					var wg sync.WaitGroup
+					for _, tag := range toDelete {
+						tag := tag
+						pool.Submit(ctx, &wg, func() {
+							defer bar.Increment()
+							signed, err := registry.Retry(ctx, 0, func() (bool, error) {
+								return r.HasSignature(imgName, tag)
+							})
+							if err != nil {
+								fmt.Printf("%s:%s signature check failed: %v\n", imgName, tag, err)
+								return
+							}
+							if signed && !c.Bool("force") {
+								fmt.Printf("%s:%s is signed, pass --force to delete it anyway\n", imgName, tag)
+								return
+							}
+							fmt.Printf("%s:%s image will be deleted ...\n", imgName, tag)
+							if _, err := registry.Retry(ctx, 0, func() (struct{}, error) {
+								return struct{}{}, r.DeleteImageByTag(imgName, tag)
+							}); err != nil {
+								fmt.Printf("%s:%s delete failed: %v\n", imgName, tag, err)
+							}
+						})
 					}
+					wg.Wait()
+					progress.Wait()
 				} else {
 					fmt.Printf("Only %d images are available\n", len(tags))
 				}
 			}
 		} else {
+			signed, err := r.HasSignature(imgName, tag)
+			if err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+			if signed && !c.Bool("force") {
+				return cli.NewExitError(fmt.Sprintf("%s:%s is signed, pass --force to delete it anyway", imgName, tag), 1)
+			}
+
 			err = r.DeleteImageByTag(imgName, tag)
 			if err != nil {
 				return cli.NewExitError(err.Error(), 1)
@@ -313,14 +659,21 @@ func deleteImage(c *cli.Context) error {
 	return nil
 }
 
+type tagSizeResult struct {
+	tag       string
+	manifests []registry.PlatformManifest
+	err       error
+}
+
 func showTotalImageSize(c *cli.Context) error {
 	var imgName = c.String("name")
+	var platform = c.String("platform")
 	var totalSize (int64) = 0
 
 	if imgName == "" {
 		cli.ShowSubcommandHelp(c)
 	} else {
-		r, err := registry.NewRegistry()
+		r, err := registry.NewRegistryWithProfile(c.GlobalString("profile"))
 		if err != nil {
 			return cli.NewExitError(err.Error(), 1)
 		}
@@ -330,24 +683,186 @@ func showTotalImageSize(c *cli.Context) error {
 			return cli.NewExitError(err.Error(), 1)
 		}
 
-		sizeInfo := make(map[string]int64)
+		ctx, cancel := cancelOnInterrupt()
+		defer cancel()
 
-		for _, tag := range tags {
-			manifest, err := r.ImageManifest(imgName, tag)
-			if err != nil {
-				return cli.NewExitError(err.Error(), 1)
-			}
+		progress := mpb.NewWithContext(ctx)
+		bar := progress.New(int64(len(tags)),
+			mpb.BarStyle().Rbound("|"),
+			mpb.PrependDecorators(decor.Name(imgName)),
+			mpb.AppendDecorators(decor.CountersNoUnit("%d / %d")),
+		)
+		bar.EnableTriggerComplete()
 
-			for _, layer := range manifest.Layers {
-				sizeInfo[layer.Digest] = layer.Size
+		pool := registry.NewPool(c.Int("concurrency"), c.Int("rate"))
+		results := make(chan tagSizeResult)
+		var wg sync.WaitGroup
+
+		// Submitting happens on its own goroutine so the range over results
+		// below can start draining immediately: if concurrency is lower than
+		// len(tags), a worker blocked sending its result would otherwise
+		// deadlock against this goroutine still being stuck submitting more
+		// work.
+		go func() {
+			for _, tag := range tags {
+				tag := tag
+				pool.Submit(ctx, &wg, func() {
+					var manifests []registry.PlatformManifest
+					var err error
+					if platform == "" {
+						manifests, err = registry.Retry(ctx, 0, func() ([]registry.PlatformManifest, error) {
+							return r.ImageManifestsForAllPlatforms(imgName, tag)
+						})
+					} else {
+						var manifest registry.ImageManifest
+						manifest, err = registry.Retry(ctx, 0, func() (registry.ImageManifest, error) {
+							return r.ImageManifestForPlatform(imgName, tag, platform)
+						})
+						manifests = []registry.PlatformManifest{{Manifest: manifest}}
+					}
+					select {
+					case results <- tagSizeResult{tag: tag, manifests: manifests, err: err}:
+					case <-ctx.Done():
+					}
+				})
 			}
+			wg.Wait()
+			close(results)
+		}()
 
+		sizeInfo := make(map[string]int64)
+		perPlatform := make(map[string]int64)
+		for result := range results {
+			bar.Increment()
+			if result.err != nil {
+				return cli.NewExitError(result.err.Error(), 1)
+			}
+			for _, pm := range result.manifests {
+				for _, layer := range pm.Manifest.Layers {
+					sizeInfo[layer.Digest] = layer.Size
+					if pm.Platform.OS != "" {
+						perPlatform[pm.Platform.String()] += layer.Size
+					}
+				}
+			}
 		}
+		progress.Wait()
+
 		for _, size := range sizeInfo {
 			totalSize += size
 		}
 
+		if len(perPlatform) > 0 {
+			fmt.Println("Per-platform breakdown:")
+			for platform, size := range perPlatform {
+				fmt.Printf("\t%s\t%d\n", platform, size)
+			}
+		}
 		fmt.Printf("%d %s\n", totalSize, imgName)
 	}
 	return nil
 }
+
+func signImage(c *cli.Context) error {
+	var imgName = c.String("name")
+	var tag = c.String("tag")
+	var keyPath = c.String("key")
+	if imgName == "" || tag == "" || keyPath == "" {
+		cli.ShowSubcommandHelp(c)
+		return nil
+	}
+
+	r, err := registry.NewRegistryWithProfile(c.GlobalString("profile"))
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	if err := r.SignTag(imgName, tag, keyPath); err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	fmt.Printf("%s:%s has been signed\n", imgName, tag)
+	return nil
+}
+
+func verifyImage(c *cli.Context) error {
+	var imgName = c.String("name")
+	var tag = c.String("tag")
+	var keyPath = c.String("key")
+	if imgName == "" || tag == "" || keyPath == "" {
+		cli.ShowSubcommandHelp(c)
+		return nil
+	}
+
+	r, err := registry.NewRegistryWithProfile(c.GlobalString("profile"))
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	info, err := r.VerifyTag(imgName, tag, keyPath)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	if !info.Valid {
+		return cli.NewExitError(fmt.Sprintf("%s:%s signature verification failed\n", imgName, tag), 1)
+	}
+	fmt.Printf("%s:%s signature is valid (%s)\n", imgName, tag, info.Digest)
+	return nil
+}
+
+func applyRetentionPolicy(c *cli.Context) error {
+	file := c.String("file")
+	if file == "" {
+		cli.ShowSubcommandHelp(c)
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	policy, err := registry.ParseRetentionPolicy(data)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	r, err := registry.NewRegistryWithProfile(c.GlobalString("profile"))
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	report, err := r.ApplyRetentionPolicy(policy, c.Bool("dry-run"))
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	body, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	fmt.Println(string(body))
+	return nil
+}
+
+func garbageCollect(c *cli.Context) error {
+	dryRun := c.Bool("dry-run")
+
+	r, err := registry.NewRegistryWithProfile(c.GlobalString("profile"))
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	report, err := r.GarbageCollect(dryRun)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	fmt.Printf("Scanned %d images, %d blobs still referenced\n", report.ScannedImages, report.ReferencedBlobs)
+	if dryRun {
+		fmt.Printf("Would reclaim %d bytes\n", report.ReclaimedBytes)
+	} else {
+		fmt.Printf("Deleted %d assets, reclaimed %d bytes\n", len(report.DeletedAssetIds), report.ReclaimedBytes)
+	}
+	return nil
+}
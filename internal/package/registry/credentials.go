@@ -0,0 +1,278 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+const (
+	envHost       = "NEXUS_HOST"
+	envUsername   = "NEXUS_USERNAME"
+	envPassword   = "NEXUS_PASSWORD"
+	envRepository = "NEXUS_REPOSITORY"
+
+	defaultProfile = "default"
+)
+
+// ErrNoCredentials is returned by NewRegistryWithProfile when none of the
+// configured CredentialStores have anything for the requested profile
+var ErrNoCredentials = errors.New("no nexus credentials found: run `nexus-cli login`, set NEXUS_HOST/NEXUS_USERNAME/NEXUS_PASSWORD, or configure docker-credential-helpers/~/.docker/config.json")
+
+// CredentialStore loads, saves, and deletes Registry credentials for a
+// named profile. Profile "" is equivalent to "default".
+type CredentialStore interface {
+	Load(profile string) (Registry, error)
+	Save(profile string, r Registry) error
+	Delete(profile string) error
+}
+
+// NewRegistry : creates a new Registry structure for the default profile
+func NewRegistry() (Registry, error) {
+	return NewRegistryWithProfile(defaultProfile)
+}
+
+// NewRegistryWithProfile : resolve credentials for profile, preferring
+// $NEXUS_HOST/$NEXUS_USERNAME/$NEXUS_PASSWORD (for CI), then falling back
+// to each CredentialStore in priority order
+func NewRegistryWithProfile(profile string) (Registry, error) {
+	if profile == "" {
+		profile = defaultProfile
+	}
+
+	if r, ok := registryFromEnv(); ok {
+		return r, nil
+	}
+
+	stores := []CredentialStore{
+		fileCredentialStore{},
+		dockerCredentialHelperStore{},
+		dockerConfigStore{},
+	}
+
+	for _, store := range stores {
+		r, err := store.Load(profile)
+		if err == nil {
+			return r, nil
+		}
+	}
+
+	return Registry{}, ErrNoCredentials
+}
+
+// Login : save r under profile using the file-based credential store
+func Login(profile string, r Registry) error {
+	if profile == "" {
+		profile = defaultProfile
+	}
+	return fileCredentialStore{}.Save(profile, r)
+}
+
+// Logout : remove the saved credentials for profile from the file-based
+// credential store
+func Logout(profile string) error {
+	if profile == "" {
+		profile = defaultProfile
+	}
+	return fileCredentialStore{}.Delete(profile)
+}
+
+func registryFromEnv() (Registry, bool) {
+	host, username, password := os.Getenv(envHost), os.Getenv(envUsername), os.Getenv(envPassword)
+	if host == "" || username == "" || password == "" {
+		return Registry{}, false
+	}
+	return Registry{Host: host, Username: username, Password: password, Repository: os.Getenv(envRepository)}, true
+}
+
+// fileCredentialStore : the original plaintext .credentials TOML file,
+// kept for backwards compatibility. Named profiles live alongside it as
+// ".credentials.<profile>".
+type fileCredentialStore struct{}
+
+func (fileCredentialStore) path(profile string) string {
+	if profile == "" || profile == defaultProfile {
+		return credentialsFile
+	}
+	return fmt.Sprintf("%s.%s", credentialsFile, profile)
+}
+
+func (s fileCredentialStore) Load(profile string) (Registry, error) {
+	var r Registry
+	path := s.path(profile)
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return r, fmt.Errorf("%s file not found: %v", path, err)
+	} else if err != nil {
+		return r, err
+	}
+
+	if _, err := toml.DecodeFile(path, &r); err != nil {
+		return r, err
+	}
+	return r, nil
+}
+
+func (s fileCredentialStore) Save(profile string, r Registry) error {
+	f, err := os.Create(s.path(profile))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return toml.NewEncoder(f).Encode(r)
+}
+
+func (s fileCredentialStore) Delete(profile string) error {
+	return os.Remove(s.path(profile))
+}
+
+// dockerCredentialHelperStore shells out to a docker-credential-helpers
+// binary (docker-credential-osxkeychain, -secretservice, -wincred, ...)
+// using its documented stdin/stdout JSON protocol
+type dockerCredentialHelperStore struct {
+	// Helper overrides the OS-specific default, e.g. "pass"
+	Helper string
+}
+
+type credentialHelperPayload struct {
+	ServerURL string `json:"ServerURL"`
+	Username  string `json:"Username"`
+	Secret    string `json:"Secret"`
+}
+
+func (s dockerCredentialHelperStore) binary() string {
+	if s.Helper != "" {
+		return "docker-credential-" + s.Helper
+	}
+
+	switch runtime.GOOS {
+	case "darwin":
+		return "docker-credential-osxkeychain"
+	case "windows":
+		return "docker-credential-wincred"
+	default:
+		return "docker-credential-secretservice"
+	}
+}
+
+func (s dockerCredentialHelperStore) Load(profile string) (Registry, error) {
+	host := os.Getenv(envHost)
+	if host == "" {
+		return Registry{}, fmt.Errorf("docker-credential-helpers store requires %s to be set", envHost)
+	}
+
+	var payload credentialHelperPayload
+	cmd := exec.Command(s.binary(), "get")
+	cmd.Stdin = strings.NewReader(host)
+	output, err := cmd.Output()
+	if err != nil {
+		return Registry{}, fmt.Errorf("%s get: %v", s.binary(), err)
+	}
+	if err := json.Unmarshal(output, &payload); err != nil {
+		return Registry{}, err
+	}
+
+	return Registry{Host: host, Username: payload.Username, Password: payload.Secret, Repository: os.Getenv(envRepository)}, nil
+}
+
+func (s dockerCredentialHelperStore) Save(profile string, r Registry) error {
+	body, err := json.Marshal(credentialHelperPayload{ServerURL: r.Host, Username: r.Username, Secret: r.Password})
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(s.binary(), "store")
+	cmd.Stdin = bytes.NewReader(body)
+	return cmd.Run()
+}
+
+func (s dockerCredentialHelperStore) Delete(profile string) error {
+	host := os.Getenv(envHost)
+	if host == "" {
+		return fmt.Errorf("docker-credential-helpers store requires %s to be set", envHost)
+	}
+
+	cmd := exec.Command(s.binary(), "erase")
+	cmd.Stdin = strings.NewReader(host)
+	return cmd.Run()
+}
+
+// dockerConfigStore reads ~/.docker/config.json, the file `docker login`
+// itself writes to, read-only (docker owns writing/erasing it)
+type dockerConfigStore struct{}
+
+type dockerConfigFile struct {
+	Auths map[string]struct {
+		Auth string `json:"auth"`
+	} `json:"auths"`
+}
+
+func (dockerConfigStore) path() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".docker", "config.json"), nil
+}
+
+func (s dockerConfigStore) Load(profile string) (Registry, error) {
+	host := os.Getenv(envHost)
+	if host == "" {
+		return Registry{}, fmt.Errorf("~/.docker/config.json store requires %s to be set", envHost)
+	}
+
+	path, err := s.path()
+	if err != nil {
+		return Registry{}, err
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Registry{}, err
+	}
+
+	var cfg dockerConfigFile
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return Registry{}, err
+	}
+
+	serverKey := strings.TrimPrefix(strings.TrimPrefix(host, "https://"), "http://")
+	entry, ok := cfg.Auths[serverKey]
+	if !ok {
+		entry, ok = cfg.Auths[host]
+	}
+	if !ok {
+		return Registry{}, fmt.Errorf("no entry for %s in %s", host, path)
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+	if err != nil {
+		return Registry{}, err
+	}
+
+	parts := strings.SplitN(string(decoded), ":", 2)
+	if len(parts) != 2 {
+		return Registry{}, fmt.Errorf("malformed auth entry for %s in %s", host, path)
+	}
+
+	return Registry{Host: host, Username: parts[0], Password: parts[1], Repository: os.Getenv(envRepository)}, nil
+}
+
+func (dockerConfigStore) Save(profile string, r Registry) error {
+	return fmt.Errorf("saving credentials to ~/.docker/config.json is not supported; use the file or docker-credential-helpers store")
+}
+
+func (dockerConfigStore) Delete(profile string) error {
+	return fmt.Errorf("deleting credentials from ~/.docker/config.json is not supported; use `docker logout`")
+}
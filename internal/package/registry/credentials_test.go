@@ -0,0 +1,134 @@
+package registry
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileCredentialStoreSaveLoadDeleteRoundTrip(t *testing.T) {
+	store := fileCredentialStore{}
+	profile := "test-round-trip"
+	path := store.path(profile)
+	t.Cleanup(func() { os.Remove(path) })
+
+	want := Registry{Host: "https://nexus.example.com", Username: "alice", Password: "hunter2", Repository: "docker-repo"}
+	if err := store.Save(profile, want); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	got, err := store.Load(profile)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if got != want {
+		t.Errorf("Load returned %+v, want %+v", got, want)
+	}
+
+	if err := store.Delete(profile); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := store.Load(profile); err == nil {
+		t.Fatalf("expected an error loading a deleted profile, got none")
+	}
+}
+
+func writeDockerConfigFixture(t *testing.T, entries map[string]string) {
+	t.Helper()
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	dockerDir := filepath.Join(home, ".docker")
+	if err := os.MkdirAll(dockerDir, 0o755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+
+	cfg := dockerConfigFile{Auths: make(map[string]struct {
+		Auth string `json:"auth"`
+	})}
+	for host, auth := range entries {
+		cfg.Auths[host] = struct {
+			Auth string `json:"auth"`
+		}{Auth: auth}
+	}
+
+	body, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dockerDir, "config.json"), body, 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+}
+
+func TestDockerConfigStoreLoad(t *testing.T) {
+	validAuth := base64.StdEncoding.EncodeToString([]byte("alice:hunter2"))
+	noColonAuth := base64.StdEncoding.EncodeToString([]byte("aliceonly"))
+
+	writeDockerConfigFixture(t, map[string]string{
+		"nexus.example.com":     validAuth,
+		"malformed.example.com": "!!!not-base64!!!",
+		"no-colon.example.com":  noColonAuth,
+	})
+
+	t.Run("found entry", func(t *testing.T) {
+		t.Setenv(envHost, "https://nexus.example.com")
+		r, err := dockerConfigStore{}.Load("default")
+		if err != nil {
+			t.Fatalf("Load: %v", err)
+		}
+		if r.Username != "alice" || r.Password != "hunter2" {
+			t.Errorf("Load returned %+v, want Username=alice Password=hunter2", r)
+		}
+	})
+
+	t.Run("no entry for host", func(t *testing.T) {
+		t.Setenv(envHost, "https://missing.example.com")
+		if _, err := (dockerConfigStore{}).Load("default"); err == nil {
+			t.Fatalf("expected an error for a host with no config.json entry, got none")
+		}
+	})
+
+	t.Run("malformed base64 auth", func(t *testing.T) {
+		t.Setenv(envHost, "https://malformed.example.com")
+		if _, err := (dockerConfigStore{}).Load("default"); err == nil {
+			t.Fatalf("expected an error decoding malformed base64, got none")
+		}
+	})
+
+	t.Run("auth missing the username:password separator", func(t *testing.T) {
+		t.Setenv(envHost, "https://no-colon.example.com")
+		if _, err := (dockerConfigStore{}).Load("default"); err == nil {
+			t.Fatalf("expected an error for an auth entry with no ':', got none")
+		}
+	})
+}
+
+func TestNewRegistryWithProfileEnvOverridesStores(t *testing.T) {
+	profile := "test-env-override"
+	path := fileCredentialStore{}.path(profile)
+	t.Cleanup(func() { os.Remove(path) })
+
+	stored := Registry{Host: "https://stored.example.com", Username: "stored-user", Password: "stored-pass", Repository: "stored-repo"}
+	if err := (fileCredentialStore{}).Save(profile, stored); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	t.Setenv(envHost, "https://env.example.com")
+	t.Setenv(envUsername, "env-user")
+	t.Setenv(envPassword, "env-pass")
+	t.Setenv(envRepository, "env-repo")
+
+	r, err := NewRegistryWithProfile(profile)
+	if err != nil {
+		t.Fatalf("NewRegistryWithProfile: %v", err)
+	}
+
+	want := Registry{Host: "https://env.example.com", Username: "env-user", Password: "env-pass", Repository: "env-repo"}
+	if r != want {
+		t.Errorf("NewRegistryWithProfile returned %+v, want the env-derived %+v (env must win over stores)", r, want)
+	}
+}
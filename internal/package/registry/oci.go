@@ -0,0 +1,209 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+const (
+	mediaTypeManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaTypeOCIIndex     = "application/vnd.oci.image.index.v1+json"
+)
+
+// manifestAcceptHeader advertises every manifest shape ImageManifest knows
+// how to decode: a plain docker v2 manifest, a docker manifest list, an OCI
+// manifest, and an OCI image index
+var manifestAcceptHeader = strings.Join([]string{
+	acceptHeader,
+	mediaTypeManifestList,
+	ociImageManifestMedia,
+	mediaTypeOCIIndex,
+}, ", ")
+
+// Platform identifies one entry of a multi-arch image index
+type Platform struct {
+	OS           string `json:"os"`
+	Architecture string `json:"architecture"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+func (p Platform) String() string {
+	if p.Variant != "" {
+		return fmt.Sprintf("%s/%s/%s", p.OS, p.Architecture, p.Variant)
+	}
+	return fmt.Sprintf("%s/%s", p.OS, p.Architecture)
+}
+
+func parsePlatform(s string) Platform {
+	parts := strings.SplitN(s, "/", 3)
+	var p Platform
+	if len(parts) > 0 {
+		p.OS = parts[0]
+	}
+	if len(parts) > 1 {
+		p.Architecture = parts[1]
+	}
+	if len(parts) > 2 {
+		p.Variant = parts[2]
+	}
+	return p
+}
+
+type imageIndexEntry struct {
+	Digest    string   `json:"digest"`
+	MediaType string   `json:"mediaType"`
+	Size      int64    `json:"size"`
+	Platform  Platform `json:"platform"`
+}
+
+// ImageIndex : docker manifest list / OCI image index
+type ImageIndex struct {
+	SchemaVersion int64             `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType"`
+	Manifests     []imageIndexEntry `json:"manifests"`
+}
+
+// PlatformManifest pairs a manifest with the platform it was built for and
+// the digest it was fetched by. For a single-arch image, Platform and
+// Digest are the zero value.
+type PlatformManifest struct {
+	Platform Platform
+	Digest   string
+	Manifest ImageManifest
+}
+
+func isIndexMediaType(mediaType string) bool {
+	return mediaType == mediaTypeManifestList || mediaType == mediaTypeOCIIndex
+}
+
+// fetchManifestByRef : GET the manifest or index for a tag or digest
+// reference, returning the raw body and Content-Type so callers can branch
+// on single-arch vs multi-arch before decoding
+func (r Registry) fetchManifestByRef(image, ref string) ([]byte, string, error) {
+	client := r.httpClient()
+
+	url := fmt.Sprintf("%s/repository/%s/v2/%s/manifests/%s", r.Host, r.Repository, image, ref)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.SetBasicAuth(r.Username, r.Password)
+	req.Header.Add("Accept", manifestAcceptHeader)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, "", &HTTPError{StatusCode: resp.StatusCode}
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return body, resp.Header.Get("Content-Type"), nil
+}
+
+// ImageManifestsForAllPlatforms : decode every platform manifest referenced
+// by image:tag. A plain single-arch manifest yields one entry with a zero
+// Platform; a manifest list / image index is expanded into one entry per
+// referenced platform.
+func (r Registry) ImageManifestsForAllPlatforms(image, tag string) ([]PlatformManifest, error) {
+	body, contentType, err := r.fetchManifestByRef(image, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isIndexMediaType(contentType) {
+		var manifest ImageManifest
+		if err := json.Unmarshal(body, &manifest); err != nil {
+			return nil, err
+		}
+		return []PlatformManifest{{Manifest: manifest}}, nil
+	}
+
+	var index ImageIndex
+	if err := json.Unmarshal(body, &index); err != nil {
+		return nil, err
+	}
+
+	manifests := make([]PlatformManifest, 0, len(index.Manifests))
+	for _, entry := range index.Manifests {
+		childBody, _, err := r.fetchManifestByRef(image, entry.Digest)
+		if err != nil {
+			return nil, err
+		}
+
+		var manifest ImageManifest
+		if err := json.Unmarshal(childBody, &manifest); err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, PlatformManifest{Platform: entry.Platform, Digest: entry.Digest, Manifest: manifest})
+	}
+
+	return manifests, nil
+}
+
+// ImageManifestForPlatform : decode the manifest for image:tag, resolving a
+// multi-arch index down to the given platform ("os/arch" or
+// "os/arch/variant"). An empty platform returns the first manifest found,
+// which for a single-arch image is simply its manifest.
+func (r Registry) ImageManifestForPlatform(image, tag, platform string) (ImageManifest, error) {
+	manifests, err := r.ImageManifestsForAllPlatforms(image, tag)
+	if err != nil {
+		return ImageManifest{}, err
+	}
+	if len(manifests) == 0 {
+		return ImageManifest{}, fmt.Errorf("no manifests found for %s:%s", image, tag)
+	}
+
+	if platform == "" {
+		return manifests[0].Manifest, nil
+	}
+
+	want := parsePlatform(platform)
+	for _, m := range manifests {
+		if m.Platform.String() == want.String() {
+			return m.Manifest, nil
+		}
+	}
+	return ImageManifest{}, fmt.Errorf("no manifest for platform %s in %s:%s", platform, image, tag)
+}
+
+// GetImageSHAForPlatform : like GetImageSHA, but for a multi-arch tag
+// resolves to the digest of the manifest matching platform instead of the
+// image index's own digest. An empty platform behaves exactly like
+// GetImageSHA.
+func (r Registry) GetImageSHAForPlatform(image, tag, platform string) (string, error) {
+	if platform == "" {
+		return r.GetImageSHA(image, tag)
+	}
+
+	body, contentType, err := r.fetchManifestByRef(image, tag)
+	if err != nil {
+		return "", err
+	}
+	if !isIndexMediaType(contentType) {
+		return r.GetImageSHA(image, tag)
+	}
+
+	var index ImageIndex
+	if err := json.Unmarshal(body, &index); err != nil {
+		return "", err
+	}
+
+	want := parsePlatform(platform)
+	for _, entry := range index.Manifests {
+		if entry.Platform.String() == want.String() {
+			return entry.Digest, nil
+		}
+	}
+	return "", fmt.Errorf("no manifest for platform %s in %s:%s", platform, image, tag)
+}
@@ -0,0 +1,204 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// WalkOptions configures how Walk fans work out across the worker pool
+type WalkOptions struct {
+	// Concurrency is the number of goroutines issuing requests in parallel.
+	// Defaults to runtime.NumCPU() when <= 0.
+	Concurrency int
+	// RatePerSecond caps how many per-tag manifest fetches start per second
+	// across the whole walk; ListTagsByImage calls and retries are not
+	// counted. 0 means unlimited.
+	RatePerSecond int
+	// MaxAttempts bounds retries on 429/5xx responses. Defaults to 3.
+	MaxAttempts int
+}
+
+// WalkResult is emitted on Walk's channel for every image:tag pair visited
+type WalkResult struct {
+	Image    string
+	Tag      string
+	Manifest ImageManifest
+	Err      error
+}
+
+// Walk streams the manifest of every tag of every image in the repository,
+// fanning the ImageManifest calls out across a bounded pool of goroutines.
+// Cancelling ctx stops new work from being submitted and closes the channel
+// once in-flight requests drain.
+func (r Registry) Walk(ctx context.Context, opts WalkOptions) <-chan WalkResult {
+	out := make(chan WalkResult)
+	pool := NewPool(opts.Concurrency, opts.RatePerSecond)
+
+	go func() {
+		defer close(out)
+
+		images, err := r.ListImages()
+		if err != nil {
+			out <- WalkResult{Err: err}
+			return
+		}
+
+		var done sync.WaitGroup
+		for _, image := range images {
+			image := image
+
+			if ctx.Err() != nil {
+				break
+			}
+
+			tags, err := r.ListTagsByImage(image)
+			if err != nil {
+				select {
+				case out <- WalkResult{Image: image, Err: err}:
+				case <-ctx.Done():
+				}
+				continue
+			}
+
+			for _, tag := range tags {
+				tag := tag
+				if ctx.Err() != nil {
+					break
+				}
+
+				pool.Submit(ctx, &done, func() {
+					manifest, err := Retry(ctx, opts.MaxAttempts, func() (ImageManifest, error) {
+						return r.ImageManifest(image, tag)
+					})
+					select {
+					case out <- WalkResult{Image: image, Tag: tag, Manifest: manifest, Err: err}:
+					case <-ctx.Done():
+					}
+				})
+			}
+		}
+
+		done.Wait()
+	}()
+
+	return out
+}
+
+// Retry runs fn, retrying with exponential backoff while it returns a
+// retryable *HTTPError (429 or 5xx), up to maxAttempts tries. It underlies
+// Walk but is exported so other bulk operations (size, tags, delete --keep)
+// can get the same 429/5xx resilience around their own pool.Submit calls.
+func Retry[T any](ctx context.Context, maxAttempts int, fn func() (T, error)) (T, error) {
+	if maxAttempts <= 0 {
+		maxAttempts = 3
+	}
+
+	backoff := 250 * time.Millisecond
+	var result T
+	var err error
+
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		result, err = fn()
+		if err == nil {
+			return result, nil
+		}
+
+		var httpErr *HTTPError
+		if !errors.As(err, &httpErr) || !httpErr.Retryable() || attempt == maxAttempts {
+			return result, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return result, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+
+	return result, err
+}
+
+// Pool bounds concurrency and, optionally, request rate across a set of
+// submitted tasks. It underlies Walk but is exported so other bulk
+// operations (size, delete --keep) can share the same fan-out behavior.
+type Pool struct {
+	sem     chan struct{}
+	limiter *rateLimiter
+}
+
+// NewPool creates a Pool allowing up to concurrency tasks to run at once,
+// optionally capped to ratePerSecond task starts per second (0 = unlimited).
+// concurrency <= 0 defaults to runtime.NumCPU().
+func NewPool(concurrency, ratePerSecond int) *Pool {
+	if concurrency <= 0 {
+		concurrency = runtime.NumCPU()
+	}
+
+	p := &Pool{sem: make(chan struct{}, concurrency)}
+	if ratePerSecond > 0 {
+		p.limiter = newRateLimiter(ratePerSecond)
+	}
+	return p
+}
+
+// Submit adds one to wg, then blocks until a worker slot is free (or ctx is
+// cancelled), then runs task on its own goroutine. wg is marked done exactly
+// once per Submit call regardless of whether ctx was cancelled before a slot
+// was acquired, so callers can safely wg.Wait() after submitting.
+func (p *Pool) Submit(ctx context.Context, wg *sync.WaitGroup, task func()) {
+	wg.Add(1)
+
+	select {
+	case p.sem <- struct{}{}:
+	case <-ctx.Done():
+		wg.Done()
+		return
+	}
+
+	go func() {
+		defer wg.Done()
+		defer func() { <-p.sem }()
+		if p.limiter != nil {
+			p.limiter.wait(ctx)
+		}
+		task()
+	}()
+}
+
+// rateLimiter is a minimal token bucket: one token is added every
+// 1/ratePerSecond, capped at ratePerSecond tokens
+type rateLimiter struct {
+	tokens chan struct{}
+}
+
+func newRateLimiter(ratePerSecond int) *rateLimiter {
+	rl := &rateLimiter{tokens: make(chan struct{}, ratePerSecond)}
+	for i := 0; i < ratePerSecond; i++ {
+		rl.tokens <- struct{}{}
+	}
+
+	interval := time.Second / time.Duration(ratePerSecond)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			select {
+			case rl.tokens <- struct{}{}:
+			default:
+			}
+		}
+	}()
+
+	return rl
+}
+
+func (rl *rateLimiter) wait(ctx context.Context) {
+	select {
+	case <-rl.tokens:
+	case <-ctx.Done():
+	}
+}
@@ -5,10 +5,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
-	"os"
 	"time"
-
-	"github.com/BurntSushi/toml"
 )
 
 const acceptHeader = "application/vnd.docker.distribution.manifest.v2+json"
@@ -21,6 +18,53 @@ type Registry struct {
 	Username   string `toml:"nexus_username"`
 	Password   string `toml:"nexus_password"`
 	Repository string `toml:"nexus_repository"`
+	client     *http.Client
+}
+
+// defaultClient is shared by every Registry value that wasn't given its own
+// http.Client, so repeated calls reuse pooled/keep-alive connections instead
+// of dialing fresh ones every time
+var defaultClient = &http.Client{
+	Transport: &http.Transport{
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 100,
+		IdleConnTimeout:     90 * time.Second,
+	},
+}
+
+func (r Registry) httpClient() *http.Client {
+	if r.client != nil {
+		return r.client
+	}
+	return defaultClient
+}
+
+// WithClient : returns a copy of r that issues requests through client
+// instead of the shared default, e.g. to tune pooling or inject a transport
+// for testing
+func (r Registry) WithClient(client *http.Client) Registry {
+	r.client = client
+	return r
+}
+
+// HTTPError : a non-2xx response from the Nexus API, carrying the status
+// code so callers (e.g. Walk's retry logic) can distinguish transient
+// failures from permanent ones
+type HTTPError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *HTTPError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("HTTP Code: %d", e.StatusCode)
+	}
+	return fmt.Sprintf("HTTP Code: %d, %s", e.StatusCode, e.Message)
+}
+
+// Retryable : whether the request that produced this error is worth retrying
+func (e *HTTPError) Retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
 }
 
 type repositories struct {
@@ -59,6 +103,7 @@ type SearchAssets struct {
 			ID          string `json:"id"`
 			Repository  string `json:"repository"`
 			Format      string `json:"format"`
+			FileSize    int64  `json:"fileSize"`
 			Checksum    struct {
 				Sha1   string `json:"sha1"`
 				Sha256 string `json:"sha256"`
@@ -68,28 +113,22 @@ type SearchAssets struct {
 	ContinuationToken interface{} `json:"continuationToken"`
 }
 
-func EncodeParam(s string) string {
-	return url.QueryEscape(s)
+// GCReport summarizes the outcome of a GarbageCollect run
+type GCReport struct {
+	DryRun          bool
+	ScannedImages   int
+	ReferencedBlobs int
+	DeletedAssetIds []string
+	ReclaimedBytes  int64
 }
 
-// NewRegistry : creates new Registry structure
-func NewRegistry() (Registry, error) {
-	r := Registry{}
-	if _, err := os.Stat(credentialsFile); os.IsNotExist(err) {
-		return r, fmt.Errorf("%s file not found: %v", credentialsFile, err)
-	} else if err != nil {
-		return r, err
-	}
-
-	if _, err := toml.DecodeFile(credentialsFile, &r); err != nil {
-		return r, err
-	}
-	return r, nil
+func EncodeParam(s string) string {
+	return url.QueryEscape(s)
 }
 
 // ListImages : List images in Nexus Docker registry
 func (r Registry) ListImages() ([]string, error) {
-	client := &http.Client{}
+	client := r.httpClient()
 
 	url := fmt.Sprintf("%s/repository/%s/v2/_catalog", r.Host, r.Repository)
 	req, err := http.NewRequest("GET", url, nil)
@@ -106,7 +145,7 @@ func (r Registry) ListImages() ([]string, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("HTTP Code: %d", resp.StatusCode)
+		return nil, &HTTPError{StatusCode: resp.StatusCode}
 	}
 
 	var repositories repositories
@@ -117,7 +156,7 @@ func (r Registry) ListImages() ([]string, error) {
 
 // ListTagsByImage : list image tags in Nexus Docker registry
 func (r Registry) ListTagsByImage(image string) ([]string, error) {
-	client := &http.Client{}
+	client := r.httpClient()
 
 	url := fmt.Sprintf("%s/repository/%s/v2/%s/tags/list", r.Host, r.Repository, image)
 	req, err := http.NewRequest("GET", url, nil)
@@ -134,7 +173,7 @@ func (r Registry) ListTagsByImage(image string) ([]string, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("HTTP Code: %d", resp.StatusCode)
+		return nil, &HTTPError{StatusCode: resp.StatusCode}
 	}
 
 	var imageTags imageTags
@@ -143,32 +182,11 @@ func (r Registry) ListTagsByImage(image string) ([]string, error) {
 	return imageTags.Tags, nil
 }
 
-// ImageManifest : get docker image manifest from registry
+// ImageManifest : get docker image manifest from registry. For a multi-arch
+// tag (manifest list / OCI image index), this resolves to the first
+// platform listed; use ImageManifestForPlatform to pick a specific one.
 func (r Registry) ImageManifest(image string, tag string) (ImageManifest, error) {
-	var imageManifest ImageManifest
-	client := &http.Client{}
-
-	url := fmt.Sprintf("%s/repository/%s/v2/%s/manifests/%s", r.Host, r.Repository, image, tag)
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return imageManifest, err
-	}
-	req.SetBasicAuth(r.Username, r.Password)
-	req.Header.Add("Accept", acceptHeader)
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return imageManifest, err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return imageManifest, fmt.Errorf("HTTP Code: %d", resp.StatusCode)
-	}
-
-	json.NewDecoder(resp.Body).Decode(&imageManifest)
-
-	return imageManifest, nil
+	return r.ImageManifestForPlatform(image, tag, "")
 }
 
 // DeleteImageByTag : delete specific image tag from registry
@@ -192,7 +210,7 @@ func (r Registry) DeleteImageByTag(image string, tag string) error {
 
 // DeleteImageByTagByAssetId : delete specific image tag from registry by the assetId
 func (r Registry) DeleteImageByTagByAssetId(assetId string, image string, tag string) error {
-	client := &http.Client{}
+	client := r.httpClient()
 
 	url := fmt.Sprintf("%s/service/rest/v1/assets/%s", r.Host, assetId)
 
@@ -210,7 +228,7 @@ func (r Registry) DeleteImageByTagByAssetId(assetId string, image string, tag st
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 202 {
-		return fmt.Errorf("HTTP Code: %d, Failed to delete image by assetId: %s, %s:%s", resp.StatusCode, assetId, image, tag)
+		return &HTTPError{StatusCode: resp.StatusCode, Message: fmt.Sprintf("failed to delete image by assetId: %s, %s:%s", assetId, image, tag)}
 	}
 
 	return nil
@@ -219,7 +237,7 @@ func (r Registry) DeleteImageByTagByAssetId(assetId string, image string, tag st
 // SearchAssets : search for assets
 func (r Registry) SearchAssets(image string, tag string) (SearchAssets, error) {
 	var searchAsset SearchAssets
-	client := &http.Client{}
+	client := r.httpClient()
 
 	url := fmt.Sprintf("%s/service/rest/v1/search?repository=%s&name=%s&version=%s", r.Host, r.Repository, image, tag)
 
@@ -237,7 +255,7 @@ func (r Registry) SearchAssets(image string, tag string) (SearchAssets, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		return searchAsset, fmt.Errorf("HTTP Code: %d", resp.StatusCode)
+		return searchAsset, &HTTPError{StatusCode: resp.StatusCode}
 	}
 
 	json.NewDecoder(resp.Body).Decode(&searchAsset)
@@ -245,8 +263,15 @@ func (r Registry) SearchAssets(image string, tag string) (SearchAssets, error) {
 	return searchAsset, nil
 }
 
+// GetImageSHA : get the docker-content-digest of image:tag. For a
+// multi-arch tag this is the digest of the manifest list / image index
+// itself, not any one platform's manifest.
+func (r Registry) GetImageSHA(image string, tag string) (string, error) {
+	return r.getImageSHA(image, tag)
+}
+
 func (r Registry) getImageSHA(image string, tag string) (string, error) {
-	client := &http.Client{}
+	client := r.httpClient()
 
 	url := fmt.Sprintf("%s/repository/%s/v2/%s/manifests/%s", r.Host, r.Repository, image, tag)
 
@@ -266,7 +291,7 @@ func (r Registry) getImageSHA(image string, tag string) (string, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("HTTP Code: %d, Failed to fetch image sha", resp.StatusCode)
+		return "", &HTTPError{StatusCode: resp.StatusCode, Message: "failed to fetch image sha"}
 	}
 
 	return resp.Header.Get("docker-content-digest"), nil
@@ -274,7 +299,7 @@ func (r Registry) getImageSHA(image string, tag string) (string, error) {
 
 // GetImageTagDate : get last modified date for the image tag
 func (r Registry) GetImageTagDate(image string, tag string) (time.Time, error) {
-	client := &http.Client{}
+	client := r.httpClient()
 
 	url := fmt.Sprintf("%s/repository/%s/v2/%s/manifests/%s", r.Host, r.Repository, image, tag)
 	req, err := http.NewRequest("GET", url, nil)
@@ -291,7 +316,7 @@ func (r Registry) GetImageTagDate(image string, tag string) (time.Time, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		return time.Now(), fmt.Errorf("HTTP Code: %d", resp.StatusCode)
+		return time.Now(), &HTTPError{StatusCode: resp.StatusCode}
 	}
 
 	t, err := time.Parse(time.RFC1123, resp.Header.Get("last-modified"))
@@ -302,3 +327,161 @@ func (r Registry) GetImageTagDate(image string, tag string) (time.Time, error) {
 
 	return t, nil
 }
+
+// searchAllAssets : enumerate every docker asset in the repository, following
+// the search API's continuationToken until exhausted
+func (r Registry) searchAllAssets() (SearchAssets, error) {
+	var all SearchAssets
+	client := r.httpClient()
+
+	token := ""
+	for {
+		url := fmt.Sprintf("%s/service/rest/v1/search?repository=%s&format=docker", r.Host, r.Repository)
+		if token != "" {
+			url = fmt.Sprintf("%s&continuationToken=%s", url, EncodeParam(token))
+		}
+
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return all, err
+		}
+		req.SetBasicAuth(r.Username, r.Password)
+		req.Header.Add("Accept", acceptHeaderJson)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return all, err
+		}
+
+		if resp.StatusCode != 200 {
+			resp.Body.Close()
+			return all, &HTTPError{StatusCode: resp.StatusCode}
+		}
+
+		var page SearchAssets
+		json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+
+		all.Items = append(all.Items, page.Items...)
+
+		next, ok := page.ContinuationToken.(string)
+		if !ok || next == "" {
+			break
+		}
+		token = next
+	}
+
+	return all, nil
+}
+
+// referencedDigests builds the set of every blob/layer digest reachable from
+// the current tags, i.e. the digests GarbageCollect must never delete
+func (r Registry) referencedDigests() (map[string]bool, int, error) {
+	referenced := make(map[string]bool)
+
+	images, err := r.ListImages()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for _, image := range images {
+		tags, err := r.ListTagsByImage(image)
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, tag := range tags {
+			// Child manifests of a multi-arch index are themselves assets in
+			// the repository, so they must be marked referenced too or GC
+			// would delete them out from under the index.
+			manifests, err := r.ImageManifestsForAllPlatforms(image, tag)
+			if err != nil {
+				return nil, 0, err
+			}
+			for _, pm := range manifests {
+				if pm.Digest != "" {
+					referenced[trimDigestPrefix(pm.Digest)] = true
+				}
+				referenced[trimDigestPrefix(pm.Manifest.Config.Digest)] = true
+				for _, layer := range pm.Manifest.Layers {
+					referenced[trimDigestPrefix(layer.Digest)] = true
+				}
+			}
+
+			digest, err := r.GetImageSHA(image, tag)
+			if err == nil {
+				referenced[trimDigestPrefix(digest)] = true
+			}
+		}
+	}
+
+	return referenced, len(images), nil
+}
+
+func trimDigestPrefix(digest string) string {
+	const prefix = "sha256:"
+	if len(digest) > len(prefix) && digest[:len(prefix)] == prefix {
+		return digest[len(prefix):]
+	}
+	return digest
+}
+
+// GarbageCollect : reclaim space held by blobs that are no longer referenced
+// by any tag. Deleting a tag only removes its manifest/asset entry, so layer
+// blobs pushed by now-deleted tags linger as orphans; GarbageCollect finds
+// and removes them. When dryRun is true, nothing is deleted and the report
+// only describes what would have been removed.
+func (r Registry) GarbageCollect(dryRun bool) (GCReport, error) {
+	report := GCReport{DryRun: dryRun}
+
+	referenced, scanned, err := r.referencedDigests()
+	if err != nil {
+		return report, err
+	}
+	report.ScannedImages = scanned
+	report.ReferencedBlobs = len(referenced)
+
+	assets, err := r.searchAllAssets()
+	if err != nil {
+		return report, err
+	}
+
+	unreferenced := make(map[string]bool)
+	for _, item := range assets.Items {
+		for _, asset := range item.Assets {
+			if asset.Checksum.Sha256 != "" && !referenced[asset.Checksum.Sha256] {
+				unreferenced[asset.ID] = true
+			}
+		}
+	}
+
+	// Re-fetch the manifest list to guard against tags pushed while we were
+	// enumerating assets, then only delete what is unreferenced in both sets
+	referencedAfter, _, err := r.referencedDigests()
+	if err != nil {
+		return report, err
+	}
+
+	for _, item := range assets.Items {
+		for _, asset := range item.Assets {
+			if !unreferenced[asset.ID] {
+				continue
+			}
+			if referencedAfter[asset.Checksum.Sha256] {
+				continue
+			}
+
+			report.ReclaimedBytes += asset.FileSize
+			if dryRun {
+				fmt.Printf("[dry-run] would delete asset %s (%s, %d bytes)\n", asset.ID, asset.Checksum.Sha256, asset.FileSize)
+				continue
+			}
+
+			if err := r.DeleteImageByTagByAssetId(asset.ID, item.Name, item.Version); err != nil {
+				return report, err
+			}
+			report.DeletedAssetIds = append(report.DeletedAssetIds, asset.ID)
+		}
+	}
+
+	return report, nil
+}
@@ -0,0 +1,643 @@
+package registry
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestRegistry wires a Registry at mux's server, with the basic-auth
+// credentials every test below asserts against
+func newTestRegistry(server *httptest.Server) Registry {
+	return Registry{Host: server.URL, Repository: "docker-repo", Username: "user", Password: "pass"}
+}
+
+func assertBasicAuth(t *testing.T, r *http.Request) {
+	t.Helper()
+	username, password, ok := r.BasicAuth()
+	if !ok || username != "user" || password != "pass" {
+		t.Errorf("expected basic auth user:pass, got %q:%q (present: %v)", username, password, ok)
+	}
+}
+
+func TestListImages(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		statusCode int
+		wantErr    bool
+		wantImages []string
+	}{
+		{name: "happy path", body: `{"repositories":["app","web"]}`, statusCode: 200, wantImages: []string{"app", "web"}},
+		{name: "unauthorized", statusCode: 401, wantErr: true},
+		{name: "server error", statusCode: 500, wantErr: true},
+		{name: "malformed json", body: `not json`, statusCode: 200, wantImages: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.HandleFunc("/repository/docker-repo/v2/_catalog", func(w http.ResponseWriter, r *http.Request) {
+				assertBasicAuth(t, r)
+				w.WriteHeader(tt.statusCode)
+				fmt.Fprint(w, tt.body)
+			})
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			images, err := newTestRegistry(server).ListImages()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(images) != len(tt.wantImages) {
+				t.Fatalf("got %v, want %v", images, tt.wantImages)
+			}
+		})
+	}
+}
+
+func TestListTagsByImage(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		wantErr    bool
+	}{
+		{name: "happy path", statusCode: 200},
+		{name: "not found", statusCode: 404, wantErr: true},
+		{name: "server error", statusCode: 500, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.HandleFunc("/repository/docker-repo/v2/app/tags/list", func(w http.ResponseWriter, r *http.Request) {
+				assertBasicAuth(t, r)
+				w.WriteHeader(tt.statusCode)
+				json.NewEncoder(w).Encode(imageTags{Name: "app", Tags: []string{"1.0.0", "1.1.0"}})
+			})
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			tags, err := newTestRegistry(server).ListTagsByImage("app")
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(tags) != 2 {
+				t.Fatalf("got %v, want 2 tags", tags)
+			}
+		})
+	}
+}
+
+func TestImageManifestSingleArch(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repository/docker-repo/v2/app/manifests/latest", func(w http.ResponseWriter, r *http.Request) {
+		assertBasicAuth(t, r)
+		w.Header().Set("Content-Type", acceptHeader)
+		json.NewEncoder(w).Encode(ImageManifest{
+			SchemaVersion: 2,
+			Config:        layerInfo{Digest: "sha256:config", Size: 10},
+			Layers:        []layerInfo{{Digest: "sha256:layer1", Size: 100}},
+		})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	manifest, err := newTestRegistry(server).ImageManifest("app", "latest")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(manifest.Layers) != 1 || manifest.Layers[0].Size != 100 {
+		t.Fatalf("unexpected manifest: %+v", manifest)
+	}
+}
+
+func TestImageManifestMultiArch(t *testing.T) {
+	for _, mediaType := range []string{mediaTypeManifestList, mediaTypeOCIIndex} {
+		t.Run(mediaType, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.HandleFunc("/repository/docker-repo/v2/app/manifests/latest", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", mediaType)
+				json.NewEncoder(w).Encode(ImageIndex{
+					SchemaVersion: 2,
+					MediaType:     mediaType,
+					Manifests: []imageIndexEntry{
+						{Digest: "sha256:amd64manifest", Platform: Platform{OS: "linux", Architecture: "amd64"}},
+						{Digest: "sha256:armmanifest", Platform: Platform{OS: "linux", Architecture: "arm64"}},
+					},
+				})
+			})
+			mux.HandleFunc("/repository/docker-repo/v2/app/manifests/sha256:amd64manifest", func(w http.ResponseWriter, r *http.Request) {
+				json.NewEncoder(w).Encode(ImageManifest{Layers: []layerInfo{{Digest: "sha256:amd64layer", Size: 111}}})
+			})
+			mux.HandleFunc("/repository/docker-repo/v2/app/manifests/sha256:armmanifest", func(w http.ResponseWriter, r *http.Request) {
+				json.NewEncoder(w).Encode(ImageManifest{Layers: []layerInfo{{Digest: "sha256:armlayer", Size: 222}}})
+			})
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			r := newTestRegistry(server)
+
+			manifest, err := r.ImageManifestForPlatform("app", "latest", "linux/arm64")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(manifest.Layers) != 1 || manifest.Layers[0].Size != 222 {
+				t.Fatalf("unexpected manifest for linux/arm64: %+v", manifest)
+			}
+
+			all, err := r.ImageManifestsForAllPlatforms("app", "latest")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(all) != 2 {
+				t.Fatalf("expected 2 platform manifests, got %d", len(all))
+			}
+		})
+	}
+}
+
+func TestImageManifestMalformedJSON(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repository/docker-repo/v2/app/manifests/latest", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "{not json")
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	if _, err := newTestRegistry(server).ImageManifest("app", "latest"); err == nil {
+		t.Fatalf("expected an error decoding malformed JSON, got none")
+	}
+}
+
+func TestImageManifestForPlatformEmptyIndexReturnsError(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repository/docker-repo/v2/app/manifests/latest", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", mediaTypeOCIIndex)
+		json.NewEncoder(w).Encode(ImageIndex{SchemaVersion: 2, MediaType: mediaTypeOCIIndex})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	if _, err := newTestRegistry(server).ImageManifestForPlatform("app", "latest", ""); err == nil {
+		t.Fatalf("expected an error for an image index with no manifests, got none")
+	}
+}
+
+func TestSearchAssetsPagination(t *testing.T) {
+	mux := http.NewServeMux()
+	calls := 0
+	mux.HandleFunc("/service/rest/v1/search", func(w http.ResponseWriter, r *http.Request) {
+		assertBasicAuth(t, r)
+		calls++
+		if r.URL.Query().Get("continuationToken") == "" {
+			fmt.Fprint(w, `{"items":[{"name":"app","version":"1.0.0"}],"continuationToken":"page2"}`)
+			return
+		}
+		fmt.Fprint(w, `{"items":[{"name":"app","version":"2.0.0"}],"continuationToken":null}`)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	assets, err := newTestRegistry(server).searchAllAssets()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected 2 paginated requests, got %d", calls)
+	}
+	if len(assets.Items) != 2 {
+		t.Fatalf("expected items from both pages, got %d", len(assets.Items))
+	}
+}
+
+func TestDeleteImageByTagByAssetId(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		wantErr    bool
+	}{
+		{name: "accepted", statusCode: 202},
+		{name: "server error", statusCode: 500, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.HandleFunc("/service/rest/v1/assets/asset-1", func(w http.ResponseWriter, r *http.Request) {
+				assertBasicAuth(t, r)
+				if r.Method != http.MethodDelete {
+					t.Errorf("expected DELETE, got %s", r.Method)
+				}
+				w.WriteHeader(tt.statusCode)
+			})
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			err := newTestRegistry(server).DeleteImageByTagByAssetId("asset-1", "app", "1.0.0")
+			if tt.wantErr && err == nil {
+				t.Fatalf("expected an error, got none")
+			}
+			if !tt.wantErr && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestGetImageTagDate(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repository/docker-repo/v2/app/manifests/latest", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("last-modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	date, err := newTestRegistry(server).GetImageTagDate("app", "latest")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := time.Date(2006, time.January, 2, 15, 4, 5, 0, time.UTC)
+	if !date.Equal(want) {
+		t.Fatalf("got %v, want %v", date, want)
+	}
+}
+
+func TestGarbageCollect(t *testing.T) {
+	var deletedAssetIDs []string
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repository/docker-repo/v2/_catalog", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(repositories{Images: []string{"app"}})
+	})
+	mux.HandleFunc("/repository/docker-repo/v2/app/tags/list", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(imageTags{Name: "app", Tags: []string{"v1"}})
+	})
+	mux.HandleFunc("/repository/docker-repo/v2/app/manifests/v1", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("docker-content-digest", "sha256:manifestdigest")
+		json.NewEncoder(w).Encode(ImageManifest{
+			SchemaVersion: 2,
+			Config:        layerInfo{Digest: "sha256:configdigest"},
+			Layers:        []layerInfo{{Digest: "sha256:referencedlayer"}},
+		})
+	})
+	mux.HandleFunc("/service/rest/v1/search", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"items":[
+			{"name":"app","version":"v1","assets":[{"id":"asset-referenced","fileSize":10,"checksum":{"sha256":"referencedlayer"}}]},
+			{"name":"app","version":"v1","assets":[{"id":"asset-orphan","fileSize":20,"checksum":{"sha256":"orphanlayer"}}]}
+		]}`)
+	})
+	mux.HandleFunc("/service/rest/v1/assets/", func(w http.ResponseWriter, r *http.Request) {
+		deletedAssetIDs = append(deletedAssetIDs, strings.TrimPrefix(r.URL.Path, "/service/rest/v1/assets/"))
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	report, err := newTestRegistry(server).GarbageCollect(false)
+	if err != nil {
+		t.Fatalf("GarbageCollect: %v", err)
+	}
+
+	if len(report.DeletedAssetIds) != 1 || report.DeletedAssetIds[0] != "asset-orphan" {
+		t.Errorf("expected only asset-orphan to be deleted, got %v", report.DeletedAssetIds)
+	}
+	if len(deletedAssetIDs) != 1 || deletedAssetIDs[0] != "asset-orphan" {
+		t.Errorf("expected only asset-orphan to be deleted on the wire, got %v", deletedAssetIDs)
+	}
+	if report.ReclaimedBytes != 20 {
+		t.Errorf("expected 20 reclaimed bytes, got %d", report.ReclaimedBytes)
+	}
+}
+
+func TestGarbageCollectPreservesMultiArchChildManifests(t *testing.T) {
+	var deletedAssetIDs []string
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repository/docker-repo/v2/_catalog", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(repositories{Images: []string{"app"}})
+	})
+	mux.HandleFunc("/repository/docker-repo/v2/app/tags/list", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(imageTags{Name: "app", Tags: []string{"v1"}})
+	})
+	mux.HandleFunc("/repository/docker-repo/v2/app/manifests/v1", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("docker-content-digest", "sha256:indexdigest")
+		w.Header().Set("Content-Type", mediaTypeOCIIndex)
+		json.NewEncoder(w).Encode(ImageIndex{
+			SchemaVersion: 2,
+			MediaType:     mediaTypeOCIIndex,
+			Manifests: []imageIndexEntry{
+				{Digest: "sha256:amd64manifest", Platform: Platform{OS: "linux", Architecture: "amd64"}},
+				{Digest: "sha256:armmanifest", Platform: Platform{OS: "linux", Architecture: "arm64"}},
+			},
+		})
+	})
+	mux.HandleFunc("/repository/docker-repo/v2/app/manifests/sha256:amd64manifest", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(ImageManifest{Config: layerInfo{Digest: "sha256:amd64config"}, Layers: []layerInfo{{Digest: "sha256:amd64layer"}}})
+	})
+	mux.HandleFunc("/repository/docker-repo/v2/app/manifests/sha256:armmanifest", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(ImageManifest{Config: layerInfo{Digest: "sha256:armconfig"}, Layers: []layerInfo{{Digest: "sha256:armlayer"}}})
+	})
+	mux.HandleFunc("/service/rest/v1/search", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"items":[
+			{"name":"app","version":"v1","assets":[{"id":"asset-amd64manifest","fileSize":1,"checksum":{"sha256":"amd64manifest"}}]},
+			{"name":"app","version":"v1","assets":[{"id":"asset-armmanifest","fileSize":1,"checksum":{"sha256":"armmanifest"}}]},
+			{"name":"app","version":"v1","assets":[{"id":"asset-amd64layer","fileSize":10,"checksum":{"sha256":"amd64layer"}}]},
+			{"name":"app","version":"v1","assets":[{"id":"asset-armlayer","fileSize":10,"checksum":{"sha256":"armlayer"}}]},
+			{"name":"app","version":"v1","assets":[{"id":"asset-orphan","fileSize":20,"checksum":{"sha256":"orphanlayer"}}]}
+		]}`)
+	})
+	mux.HandleFunc("/service/rest/v1/assets/", func(w http.ResponseWriter, r *http.Request) {
+		deletedAssetIDs = append(deletedAssetIDs, strings.TrimPrefix(r.URL.Path, "/service/rest/v1/assets/"))
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	report, err := newTestRegistry(server).GarbageCollect(false)
+	if err != nil {
+		t.Fatalf("GarbageCollect: %v", err)
+	}
+
+	if len(report.DeletedAssetIds) != 1 || report.DeletedAssetIds[0] != "asset-orphan" {
+		t.Errorf("expected only asset-orphan to be deleted, a multi-arch tag's child manifests must survive GC, got %v", report.DeletedAssetIds)
+	}
+	if len(deletedAssetIDs) != 1 || deletedAssetIDs[0] != "asset-orphan" {
+		t.Errorf("expected only asset-orphan to be deleted on the wire, got %v", deletedAssetIDs)
+	}
+}
+
+func TestGarbageCollectDryRunDeletesNothing(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repository/docker-repo/v2/_catalog", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(repositories{Images: []string{"app"}})
+	})
+	mux.HandleFunc("/repository/docker-repo/v2/app/tags/list", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(imageTags{Name: "app", Tags: []string{"v1"}})
+	})
+	mux.HandleFunc("/repository/docker-repo/v2/app/manifests/v1", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("docker-content-digest", "sha256:manifestdigest")
+		json.NewEncoder(w).Encode(ImageManifest{Config: layerInfo{Digest: "sha256:configdigest"}})
+	})
+	mux.HandleFunc("/service/rest/v1/search", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"items":[{"name":"app","version":"v1","assets":[{"id":"asset-orphan","fileSize":20,"checksum":{"sha256":"orphanlayer"}}]}]}`)
+	})
+	mux.HandleFunc("/service/rest/v1/assets/", func(w http.ResponseWriter, r *http.Request) {
+		t.Fatalf("dry-run should never call DELETE, got %s", r.URL.Path)
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	report, err := newTestRegistry(server).GarbageCollect(true)
+	if err != nil {
+		t.Fatalf("GarbageCollect: %v", err)
+	}
+	if len(report.DeletedAssetIds) != 0 {
+		t.Errorf("dry-run should report no deletions, got %v", report.DeletedAssetIds)
+	}
+	if report.ReclaimedBytes != 20 {
+		t.Errorf("expected dry-run to still report 20 reclaimable bytes, got %d", report.ReclaimedBytes)
+	}
+}
+
+// newEd25519KeyFiles writes a freshly generated ed25519 key pair to PEM files
+// under t.TempDir(), returning their paths
+func newEd25519KeyFiles(t *testing.T) (privPath, pubPath string) {
+	t.Helper()
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	privBytes, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatalf("MarshalPKCS8PrivateKey: %v", err)
+	}
+	pubBytes, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatalf("MarshalPKIXPublicKey: %v", err)
+	}
+
+	dir := t.TempDir()
+	privPath = filepath.Join(dir, "cosign.key")
+	pubPath = filepath.Join(dir, "cosign.pub")
+
+	if err := ioutil.WriteFile(privPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privBytes}), 0600); err != nil {
+		t.Fatalf("write private key: %v", err)
+	}
+	if err := ioutil.WriteFile(pubPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubBytes}), 0644); err != nil {
+		t.Fatalf("write public key: %v", err)
+	}
+	return privPath, pubPath
+}
+
+// newSigningTestServer mocks the manifest/blob endpoints SignTag and
+// VerifyTag round-trip through for a single image:tag, backed by an
+// in-memory blob store
+func newSigningTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	blobs := map[string][]byte{}
+	manifests := map[string][]byte{}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repository/docker-repo/v2/app/manifests/v1", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("docker-content-digest", "sha256:abcdef")
+		json.NewEncoder(w).Encode(ImageManifest{SchemaVersion: 2})
+	})
+	mux.HandleFunc("/repository/docker-repo/v2/app/tags/list", func(w http.ResponseWriter, r *http.Request) {
+		tags := []string{"v1"}
+		for tag := range manifests {
+			tags = append(tags, tag)
+		}
+		json.NewEncoder(w).Encode(imageTags{Name: "app", Tags: tags})
+	})
+	mux.HandleFunc("/repository/docker-repo/v2/app/manifests/", func(w http.ResponseWriter, r *http.Request) {
+		tag := strings.TrimPrefix(r.URL.Path, "/repository/docker-repo/v2/app/manifests/")
+		switch r.Method {
+		case http.MethodPut:
+			body, _ := ioutil.ReadAll(r.Body)
+			manifests[tag] = body
+			w.WriteHeader(http.StatusCreated)
+		case http.MethodGet:
+			body, ok := manifests[tag]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(body)
+		}
+	})
+	mux.HandleFunc("/repository/docker-repo/v2/app/blobs/uploads/", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			w.Header().Set("Location", "/repository/docker-repo/v2/app/blobs/uploads/session1")
+			w.WriteHeader(http.StatusAccepted)
+		case http.MethodPut:
+			digest := r.URL.Query().Get("digest")
+			body, _ := ioutil.ReadAll(r.Body)
+			blobs[digest] = body
+			w.WriteHeader(http.StatusCreated)
+		}
+	})
+	mux.HandleFunc("/repository/docker-repo/v2/app/blobs/", func(w http.ResponseWriter, r *http.Request) {
+		digest := strings.TrimPrefix(r.URL.Path, "/repository/docker-repo/v2/app/blobs/")
+		body, ok := blobs[digest]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(body)
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestSignAndVerifyTagRoundTrip(t *testing.T) {
+	privPath, pubPath := newEd25519KeyFiles(t)
+	server := newSigningTestServer(t)
+	defer server.Close()
+
+	r := newTestRegistry(server)
+
+	if err := r.SignTag("app", "v1", privPath); err != nil {
+		t.Fatalf("SignTag: %v", err)
+	}
+
+	hasSig, err := r.HasSignature("app", "v1")
+	if err != nil {
+		t.Fatalf("HasSignature: %v", err)
+	}
+	if !hasSig {
+		t.Errorf("expected HasSignature to report true after SignTag")
+	}
+
+	info, err := r.VerifyTag("app", "v1", pubPath)
+	if err != nil {
+		t.Fatalf("VerifyTag: %v", err)
+	}
+	if !info.Valid {
+		t.Errorf("expected signature to verify as valid, got %+v", info)
+	}
+}
+
+func TestVerifyTagWrongKeyFailsValidation(t *testing.T) {
+	privPath, _ := newEd25519KeyFiles(t)
+	_, otherPubPath := newEd25519KeyFiles(t)
+	server := newSigningTestServer(t)
+	defer server.Close()
+
+	r := newTestRegistry(server)
+
+	if err := r.SignTag("app", "v1", privPath); err != nil {
+		t.Fatalf("SignTag: %v", err)
+	}
+
+	info, err := r.VerifyTag("app", "v1", otherPubPath)
+	if err != nil {
+		t.Fatalf("VerifyTag: %v", err)
+	}
+	if info.Valid {
+		t.Errorf("expected signature to fail validation against the wrong public key")
+	}
+}
+
+func TestWalkCancellation(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repository/docker-repo/v2/_catalog", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(repositories{Images: []string{"app"}})
+	})
+	mux.HandleFunc("/repository/docker-repo/v2/app/tags/list", func(w http.ResponseWriter, r *http.Request) {
+		tags := make([]string, 50)
+		for i := range tags {
+			tags[i] = fmt.Sprintf("v%d", i)
+		}
+		json.NewEncoder(w).Encode(imageTags{Name: "app", Tags: tags})
+	})
+	mux.HandleFunc("/repository/docker-repo/v2/app/manifests/", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		json.NewEncoder(w).Encode(ImageManifest{SchemaVersion: 2})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	out := newTestRegistry(server).Walk(ctx, WalkOptions{Concurrency: 2})
+
+	// Cancel almost immediately so most of the pool's work is still queued
+	// when ctx.Done() fires, then make sure the channel still closes instead
+	// of Walk's done.Wait() hanging forever.
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		for range out {
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Walk did not close its output channel after cancellation")
+	}
+}
+
+func TestPoolSubmitRunsWaitGroupDoneOnCancellation(t *testing.T) {
+	pool := NewPool(1, 0)
+
+	// Saturate the pool's single slot with a task that blocks until we
+	// release it, so a subsequent Submit has to wait for a slot. It has its
+	// own WaitGroup so it doesn't affect the one under test below.
+	release := make(chan struct{})
+	defer close(release)
+	var blockerWG sync.WaitGroup
+	pool.Submit(context.Background(), &blockerWG, func() {
+		<-release
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	go func() {
+		pool.Submit(ctx, &wg, func() {})
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("wg.Wait() hung after Submit was cancelled waiting for a slot")
+	}
+}
@@ -0,0 +1,281 @@
+package registry
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// RetentionRule : one entry of a RetentionPolicy. Repository is a glob
+// matched against image names, Tag is a regex scoping which tags of a
+// matching image the rule evaluates (empty matches every tag). A tag
+// survives if it satisfies any of the rule's actions.
+type RetentionRule struct {
+	Repository   string   `yaml:"repository"`
+	Tag          string   `yaml:"tag"`
+	KeepLast     int      `yaml:"keep_last,omitempty"`
+	KeepWithin   string   `yaml:"keep_within,omitempty"`
+	KeepMatching string   `yaml:"keep_matching,omitempty"`
+	ExcludeTag   []string `yaml:"exclude_tag,omitempty"`
+}
+
+// RetentionPolicy : the top-level retention.yaml document
+type RetentionPolicy struct {
+	Rules []RetentionRule `yaml:"rules"`
+}
+
+// ParseRetentionPolicy : decode a retention.yaml document
+func ParseRetentionPolicy(data []byte) (RetentionPolicy, error) {
+	var policy RetentionPolicy
+	if err := yaml.Unmarshal(data, &policy); err != nil {
+		return policy, err
+	}
+	return policy, nil
+}
+
+// RetentionVerdict records what happened to a single image:tag when a
+// RetentionPolicy was applied
+type RetentionVerdict struct {
+	Image  string `json:"image"`
+	Tag    string `json:"tag"`
+	Action string `json:"action"` // "kept", "deleted", "would-delete", "skipped"
+	Reason string `json:"reason,omitempty"`
+}
+
+// RetentionReport summarizes an ApplyRetentionPolicy run
+type RetentionReport struct {
+	DryRun   bool               `json:"dryRun"`
+	Verdicts []RetentionVerdict `json:"verdicts"`
+}
+
+// ApplyRetentionPolicy : evaluate policy against every image/tag in the
+// repository and delete whatever no rule protects. Images that no rule's
+// repository glob matches are left untouched and get no verdicts. When
+// dryRun is true, nothing is deleted and matching tags are reported as "would-delete".
+func (r Registry) ApplyRetentionPolicy(policy RetentionPolicy, dryRun bool) (RetentionReport, error) {
+	report := RetentionReport{DryRun: dryRun}
+
+	images, err := r.ListImages()
+	if err != nil {
+		return report, err
+	}
+
+	for _, image := range images {
+		rules := rulesForImage(policy, image)
+		if len(rules) == 0 {
+			continue
+		}
+
+		tags, err := r.ListTagsByImage(image)
+		if err != nil {
+			return report, err
+		}
+
+		kept, reasons, err := r.evaluateRules(image, tags, rules)
+		if err != nil {
+			return report, err
+		}
+
+		for _, tag := range tags {
+			if kept[tag] {
+				report.Verdicts = append(report.Verdicts, RetentionVerdict{Image: image, Tag: tag, Action: "kept", Reason: reasons[tag]})
+				continue
+			}
+
+			if dryRun {
+				report.Verdicts = append(report.Verdicts, RetentionVerdict{Image: image, Tag: tag, Action: "would-delete"})
+				continue
+			}
+
+			if err := r.DeleteImageByTag(image, tag); err != nil {
+				report.Verdicts = append(report.Verdicts, RetentionVerdict{Image: image, Tag: tag, Action: "skipped", Reason: err.Error()})
+				continue
+			}
+			report.Verdicts = append(report.Verdicts, RetentionVerdict{Image: image, Tag: tag, Action: "deleted"})
+		}
+	}
+
+	return report, nil
+}
+
+func rulesForImage(policy RetentionPolicy, image string) []RetentionRule {
+	var matched []RetentionRule
+	for _, rule := range policy.Rules {
+		if rule.Repository == "" || matchRepositoryGlob(rule.Repository, image) {
+			matched = append(matched, rule)
+		}
+	}
+	return matched
+}
+
+// matchRepositoryGlob : like filepath.Match, but "*" also matches "/" since
+// Docker/Nexus image names routinely contain a namespace segment
+// (e.g. "team/app") and a policy's "*" is meant to mean "every image", not
+// "every image with no slash in its name". Only "*" and "?" are special;
+// "[...]" classes and backslashes are matched literally.
+func matchRepositoryGlob(pattern, image string) bool {
+	var re strings.Builder
+	re.WriteByte('^')
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			re.WriteString(".*")
+		case '?':
+			re.WriteByte('.')
+		default:
+			re.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	re.WriteByte('$')
+
+	matched, _ := regexp.MatchString(re.String(), image)
+	return matched
+}
+
+// evaluateRules : returns the set of tags any rule protects, and a reason
+// string per protected tag describing which rule/action kept it
+func (r Registry) evaluateRules(image string, tags []string, rules []RetentionRule) (map[string]bool, map[string]string, error) {
+	kept := make(map[string]bool)
+	reasons := make(map[string]string)
+
+	sortedByAge := append([]string{}, tags...)
+	sortSemverDescending(sortedByAge)
+
+	for _, rule := range rules {
+		scoped, err := filterByTagPattern(sortedByAge, rule.Tag)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if rule.KeepLast > 0 {
+			for i, tag := range scoped {
+				if i >= rule.KeepLast {
+					break
+				}
+				kept[tag] = true
+				reasons[tag] = fmt.Sprintf("keep_last %d (%s)", rule.KeepLast, rule.Repository)
+			}
+		}
+
+		if rule.KeepWithin != "" {
+			within, err := parseRetentionDuration(rule.KeepWithin)
+			if err != nil {
+				return nil, nil, err
+			}
+			cutoff := time.Now().Add(-within)
+			for _, tag := range scoped {
+				date, err := r.GetImageTagDate(image, tag)
+				if err != nil {
+					return nil, nil, err
+				}
+				if date.After(cutoff) {
+					kept[tag] = true
+					reasons[tag] = fmt.Sprintf("keep_within %s (%s)", rule.KeepWithin, rule.Repository)
+				}
+			}
+		}
+
+		if rule.KeepMatching != "" {
+			re, err := regexp.Compile(rule.KeepMatching)
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, tag := range scoped {
+				if re.MatchString(tag) {
+					kept[tag] = true
+					reasons[tag] = fmt.Sprintf("keep_matching %s (%s)", rule.KeepMatching, rule.Repository)
+				}
+			}
+		}
+
+		for _, excluded := range rule.ExcludeTag {
+			for _, tag := range scoped {
+				if tag == excluded {
+					kept[tag] = true
+					reasons[tag] = fmt.Sprintf("exclude_tag %s (%s)", excluded, rule.Repository)
+				}
+			}
+		}
+	}
+
+	return kept, reasons, nil
+}
+
+func filterByTagPattern(tags []string, pattern string) ([]string, error) {
+	if pattern == "" {
+		return tags, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []string
+	for _, tag := range tags {
+		if re.MatchString(tag) {
+			matched = append(matched, tag)
+		}
+	}
+	return matched, nil
+}
+
+// parseRetentionDuration : time.ParseDuration plus a "Nd" (days) suffix,
+// since retention windows are naturally expressed in days ("30d")
+func parseRetentionDuration(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil {
+			return 0, fmt.Errorf("invalid keep_within %q: %v", s, err)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// sortSemverDescending : sorts tags newest-first. Tags that parse as
+// dotted numeric versions (optionally "v"-prefixed, e.g. v1.2.3) sort by
+// version; everything else falls back to reverse lexical order after them.
+func sortSemverDescending(tags []string) {
+	sort.SliceStable(tags, func(i, j int) bool {
+		a, aOk := parseSemver(tags[i])
+		b, bOk := parseSemver(tags[j])
+
+		if aOk && bOk {
+			return compareSemver(a, b) > 0
+		}
+		if aOk != bOk {
+			return aOk
+		}
+		return tags[i] > tags[j]
+	})
+}
+
+func parseSemver(tag string) ([]int, bool) {
+	trimmed := strings.TrimPrefix(tag, "v")
+	parts := strings.Split(trimmed, ".")
+
+	nums := make([]int, 0, len(parts))
+	for _, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, false
+		}
+		nums = append(nums, n)
+	}
+	return nums, true
+}
+
+func compareSemver(a, b []int) int {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[i] - b[i]
+		}
+	}
+	return len(a) - len(b)
+}
@@ -0,0 +1,125 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newRetentionTestServer(t *testing.T, tags []string, deleted *[]string) *httptest.Server {
+	return newRetentionTestServerForImage(t, "app", tags, deleted)
+}
+
+func newRetentionTestServerForImage(t *testing.T, image string, tags []string, deleted *[]string) *httptest.Server {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/repository/docker-repo/v2/_catalog", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(repositories{Images: []string{image}})
+	})
+	mux.HandleFunc("/repository/docker-repo/v2/"+image+"/tags/list", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(imageTags{Name: image, Tags: tags})
+	})
+	mux.HandleFunc("/repository/docker-repo/v2/"+image+"/manifests/", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("last-modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		json.NewEncoder(w).Encode(ImageManifest{SchemaVersion: 2})
+	})
+	mux.HandleFunc("/service/rest/v1/search", func(w http.ResponseWriter, r *http.Request) {
+		version := r.URL.Query().Get("version")
+		fmt.Fprintf(w, `{"items":[{"name":%q,"version":%q,"assets":[{"id":"asset-%s"}]}]}`, image, version, version)
+	})
+	mux.HandleFunc("/service/rest/v1/assets/", func(w http.ResponseWriter, r *http.Request) {
+		*deleted = append(*deleted, r.URL.Path)
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestApplyRetentionPolicyKeepsProtectedTags(t *testing.T) {
+	var deleted []string
+	server := newRetentionTestServer(t, []string{"v1.0.0", "v1.1.0", "v2.0.0", "latest", "dev-1234"}, &deleted)
+	defer server.Close()
+
+	r := Registry{Host: server.URL, Repository: "docker-repo"}
+	policy := RetentionPolicy{Rules: []RetentionRule{
+		{Repository: "app", KeepLast: 1},
+		{Repository: "app", KeepMatching: `^v\d+\.\d+\.\d+$`},
+		{Repository: "app", ExcludeTag: []string{"latest"}},
+	}}
+
+	report, err := r.ApplyRetentionPolicy(policy, false)
+	if err != nil {
+		t.Fatalf("ApplyRetentionPolicy: %v", err)
+	}
+
+	kept := map[string]bool{}
+	for _, v := range report.Verdicts {
+		if v.Action == "kept" {
+			kept[v.Tag] = true
+		}
+	}
+
+	for _, tag := range []string{"v1.0.0", "v1.1.0", "v2.0.0", "latest"} {
+		if !kept[tag] {
+			t.Errorf("expected %s to be kept, verdicts: %+v", tag, report.Verdicts)
+		}
+	}
+	if kept["dev-1234"] {
+		t.Errorf("expected dev-1234 to be deleted, not kept")
+	}
+}
+
+func TestApplyRetentionPolicyCatchAllMatchesNestedRepository(t *testing.T) {
+	var deleted []string
+	server := newRetentionTestServerForImage(t, "team/app", []string{"v1.0.0", "v1.1.0", "dev-1234"}, &deleted)
+	defer server.Close()
+
+	r := Registry{Host: server.URL, Repository: "docker-repo"}
+	policy := RetentionPolicy{Rules: []RetentionRule{
+		{Repository: "*", KeepMatching: `^v\d+\.\d+\.\d+$`},
+	}}
+
+	report, err := r.ApplyRetentionPolicy(policy, false)
+	if err != nil {
+		t.Fatalf("ApplyRetentionPolicy: %v", err)
+	}
+
+	kept := map[string]bool{}
+	for _, v := range report.Verdicts {
+		if v.Action == "kept" {
+			kept[v.Tag] = true
+		}
+	}
+
+	for _, tag := range []string{"v1.0.0", "v1.1.0"} {
+		if !kept[tag] {
+			t.Errorf("expected %s to be kept by the catch-all rule, verdicts: %+v", tag, report.Verdicts)
+		}
+	}
+	if kept["dev-1234"] {
+		t.Errorf("expected dev-1234 to be deleted, not kept")
+	}
+}
+
+func TestApplyRetentionPolicyDryRun(t *testing.T) {
+	var deleted []string
+	server := newRetentionTestServer(t, []string{"stale"}, &deleted)
+	defer server.Close()
+
+	r := Registry{Host: server.URL, Repository: "docker-repo"}
+	policy := RetentionPolicy{Rules: []RetentionRule{{Repository: "app", KeepLast: 0}}}
+
+	report, err := r.ApplyRetentionPolicy(policy, true)
+	if err != nil {
+		t.Fatalf("ApplyRetentionPolicy: %v", err)
+	}
+
+	if len(deleted) != 0 {
+		t.Errorf("dry-run should not delete anything, got %v", deleted)
+	}
+	if len(report.Verdicts) != 1 || report.Verdicts[0].Action != "would-delete" {
+		t.Errorf("expected a single would-delete verdict, got %+v", report.Verdicts)
+	}
+}
@@ -0,0 +1,417 @@
+package registry
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+const (
+	cosignSignatureType   = "cosign container image signature"
+	cosignSignatureMedia  = "application/vnd.dev.cosign.simplesigning.v1+json"
+	cosignSignatureAnnot  = "dev.cosignproject.cosign/signature"
+	ociImageConfigMedia   = "application/vnd.oci.image.config.v1+json"
+	ociImageManifestMedia = "application/vnd.oci.image.manifest.v1+json"
+)
+
+// SignatureInfo describes the result of verifying a tag's signature
+type SignatureInfo struct {
+	Image     string
+	Tag       string
+	Digest    string
+	Signature string
+	Valid     bool
+}
+
+type cosignPayload struct {
+	Critical struct {
+		Identity struct {
+			DockerReference string `json:"docker-reference"`
+		} `json:"identity"`
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+	Optional interface{} `json:"optional"`
+}
+
+type ociManifest struct {
+	SchemaVersion int64            `json:"schemaVersion"`
+	MediaType     string           `json:"mediaType"`
+	Config        layerInfo        `json:"config"`
+	Layers        []signatureLayer `json:"layers"`
+}
+
+type signatureLayer struct {
+	MediaType   string            `json:"mediaType"`
+	Size        int64             `json:"size"`
+	Digest      string            `json:"digest"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+// sigTagFor : the tag a signature for the given manifest digest is stored under
+func sigTagFor(digest string) string {
+	return fmt.Sprintf("sha256-%s.sig", trimDigestPrefix(digest))
+}
+
+// SignTag : sign image:tag with the ECDSA/ed25519 private key at keyPath and
+// push the signature as a small OCI image under the "sha256-<digest>.sig" tag
+func (r Registry) SignTag(image, tag, keyPath string) error {
+	digest, err := r.getImageSHA(image, tag)
+	if err != nil {
+		return err
+	}
+
+	payload := cosignPayload{}
+	payload.Critical.Type = cosignSignatureType
+	payload.Critical.Identity.DockerReference = fmt.Sprintf("%s/%s", r.Repository, image)
+	payload.Critical.Image.DockerManifestDigest = digest
+
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	signer, err := loadPrivateKey(keyPath)
+	if err != nil {
+		return err
+	}
+
+	signature, err := signPayload(signer, payloadBytes)
+	if err != nil {
+		return err
+	}
+
+	return r.pushSignature(image, digest, payloadBytes, signature)
+}
+
+// VerifyTag : fetch the signature pushed by SignTag for image:tag and check
+// it against the public key at keyPath
+func (r Registry) VerifyTag(image, tag, keyPath string) (SignatureInfo, error) {
+	var info SignatureInfo
+
+	digest, err := r.getImageSHA(image, tag)
+	if err != nil {
+		return info, err
+	}
+	info.Image = image
+	info.Tag = tag
+	info.Digest = digest
+
+	manifest, err := r.fetchOCIManifest(image, sigTagFor(digest))
+	if err != nil {
+		return info, fmt.Errorf("no signature found for %s:%s: %v", image, tag, err)
+	}
+
+	pub, err := loadPublicKey(keyPath)
+	if err != nil {
+		return info, err
+	}
+
+	for _, layer := range manifest.Layers {
+		signature, ok := layer.Annotations[cosignSignatureAnnot]
+		if !ok {
+			continue
+		}
+
+		payload, err := r.fetchBlob(image, layer.Digest)
+		if err != nil {
+			return info, err
+		}
+
+		valid, err := verifySignature(pub, payload, signature)
+		if err != nil {
+			return info, err
+		}
+
+		info.Signature = signature
+		info.Valid = valid
+		return info, nil
+	}
+
+	return info, fmt.Errorf("signature manifest for %s:%s has no cosign signature layer", image, tag)
+}
+
+// HasSignature : report whether image:tag currently has a pushed signature,
+// without requiring a public key
+func (r Registry) HasSignature(image, tag string) (bool, error) {
+	digest, err := r.getImageSHA(image, tag)
+	if err != nil {
+		return false, err
+	}
+
+	tags, err := r.ListTagsByImage(image)
+	if err != nil {
+		return false, err
+	}
+
+	sigTag := sigTagFor(digest)
+	for _, t := range tags {
+		if t == sigTag {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (r Registry) pushSignature(image, digest string, payload []byte, signature string) error {
+	payloadDigest, payloadSize, err := r.uploadBlob(image, payload)
+	if err != nil {
+		return err
+	}
+
+	configDigest, configSize, err := r.uploadBlob(image, []byte("{}"))
+	if err != nil {
+		return err
+	}
+
+	manifest := ociManifest{
+		SchemaVersion: 2,
+		MediaType:     ociImageManifestMedia,
+		Config: layerInfo{
+			MediaType: ociImageConfigMedia,
+			Size:      configSize,
+			Digest:    configDigest,
+		},
+		Layers: []signatureLayer{
+			{
+				MediaType:   cosignSignatureMedia,
+				Size:        payloadSize,
+				Digest:      payloadDigest,
+				Annotations: map[string]string{cosignSignatureAnnot: signature},
+			},
+		},
+	}
+
+	body, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+
+	client := r.httpClient()
+	url := fmt.Sprintf("%s/repository/%s/v2/%s/manifests/%s", r.Host, r.Repository, image, sigTagFor(digest))
+	req, err := http.NewRequest("PUT", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(r.Username, r.Password)
+	req.Header.Set("Content-Type", ociImageManifestMedia)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 201 {
+		return fmt.Errorf("HTTP Code: %d, failed to push signature for %s@%s", resp.StatusCode, image, digest)
+	}
+	return nil
+}
+
+// uploadBlob : push content as a blob for image, returning its digest and size
+func (r Registry) uploadBlob(image string, content []byte) (string, int64, error) {
+	sum := sha256.Sum256(content)
+	digest := fmt.Sprintf("sha256:%x", sum)
+
+	client := r.httpClient()
+	initURL := fmt.Sprintf("%s/repository/%s/v2/%s/blobs/uploads/", r.Host, r.Repository, image)
+	initReq, err := http.NewRequest("POST", initURL, nil)
+	if err != nil {
+		return "", 0, err
+	}
+	initReq.SetBasicAuth(r.Username, r.Password)
+
+	initResp, err := client.Do(initReq)
+	if err != nil {
+		return "", 0, err
+	}
+	location := initResp.Header.Get("Location")
+	initResp.Body.Close()
+
+	if initResp.StatusCode != 202 {
+		return "", 0, fmt.Errorf("HTTP Code: %d, failed to start blob upload for %s", initResp.StatusCode, image)
+	}
+
+	if !strings.HasPrefix(location, "http") {
+		location = r.Host + location
+	}
+	separator := "?"
+	if strings.Contains(location, "?") {
+		separator = "&"
+	}
+	uploadURL := fmt.Sprintf("%s%sdigest=%s", location, separator, EncodeParam(digest))
+
+	uploadReq, err := http.NewRequest("PUT", uploadURL, bytes.NewReader(content))
+	if err != nil {
+		return "", 0, err
+	}
+	uploadReq.SetBasicAuth(r.Username, r.Password)
+	uploadReq.Header.Set("Content-Type", "application/octet-stream")
+
+	uploadResp, err := client.Do(uploadReq)
+	if err != nil {
+		return "", 0, err
+	}
+	defer uploadResp.Body.Close()
+
+	if uploadResp.StatusCode != 201 {
+		return "", 0, fmt.Errorf("HTTP Code: %d, failed to upload blob for %s", uploadResp.StatusCode, image)
+	}
+
+	return digest, int64(len(content)), nil
+}
+
+// fetchOCIManifest : fetch a manifest by tag and decode it as an OCI image
+// manifest, preserving per-layer annotations
+func (r Registry) fetchOCIManifest(image, tag string) (ociManifest, error) {
+	var manifest ociManifest
+	client := r.httpClient()
+
+	url := fmt.Sprintf("%s/repository/%s/v2/%s/manifests/%s", r.Host, r.Repository, image, tag)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return manifest, err
+	}
+	req.SetBasicAuth(r.Username, r.Password)
+	req.Header.Add("Accept", ociImageManifestMedia)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return manifest, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return manifest, fmt.Errorf("HTTP Code: %d", resp.StatusCode)
+	}
+
+	json.NewDecoder(resp.Body).Decode(&manifest)
+	return manifest, nil
+}
+
+// fetchBlob : download a blob by digest
+func (r Registry) fetchBlob(image, digest string) ([]byte, error) {
+	client := r.httpClient()
+
+	url := fmt.Sprintf("%s/repository/%s/v2/%s/blobs/%s", r.Host, r.Repository, image, digest)
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.SetBasicAuth(r.Username, r.Password)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("HTTP Code: %d", resp.StatusCode)
+	}
+
+	return ioutil.ReadAll(resp.Body)
+}
+
+func loadPrivateKey(keyPath string) (crypto.Signer, error) {
+	data, err := ioutil.ReadFile(keyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s does not contain a PEM encoded key", keyPath)
+	}
+
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported private key in %s: %v", keyPath, err)
+	}
+
+	switch k := key.(type) {
+	case *ecdsa.PrivateKey:
+		return k, nil
+	case ed25519.PrivateKey:
+		return k, nil
+	default:
+		return nil, fmt.Errorf("unsupported private key type in %s", keyPath)
+	}
+}
+
+func loadPublicKey(keyPath string) (crypto.PublicKey, error) {
+	data, err := ioutil.ReadFile(keyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s does not contain a PEM encoded key", keyPath)
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported public key in %s: %v", keyPath, err)
+	}
+
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		return k, nil
+	case ed25519.PublicKey:
+		return k, nil
+	default:
+		return nil, fmt.Errorf("unsupported public key type in %s", keyPath)
+	}
+}
+
+func signPayload(signer crypto.Signer, payload []byte) (string, error) {
+	switch key := signer.(type) {
+	case *ecdsa.PrivateKey:
+		sum := sha256.Sum256(payload)
+		sig, err := ecdsa.SignASN1(rand.Reader, key, sum[:])
+		if err != nil {
+			return "", err
+		}
+		return base64.StdEncoding.EncodeToString(sig), nil
+	case ed25519.PrivateKey:
+		sig := ed25519.Sign(key, payload)
+		return base64.StdEncoding.EncodeToString(sig), nil
+	default:
+		return "", fmt.Errorf("unsupported private key type %T", signer)
+	}
+}
+
+func verifySignature(pub crypto.PublicKey, payload []byte, signatureB64 string) (bool, error) {
+	sig, err := base64.StdEncoding.DecodeString(signatureB64)
+	if err != nil {
+		return false, err
+	}
+
+	switch key := pub.(type) {
+	case *ecdsa.PublicKey:
+		sum := sha256.Sum256(payload)
+		return ecdsa.VerifyASN1(key, sum[:], sig), nil
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, payload, sig), nil
+	default:
+		return false, fmt.Errorf("unsupported public key type %T", pub)
+	}
+}